@@ -0,0 +1,275 @@
+package apexlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/apex/log"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/helper"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
+
+type Handler struct {
+	logr      *log.Logger
+	addSource bool
+	level     *slog.LevelVar
+	attrGroup *helper.AttrGroup
+	levelMap  logger.LevelMap[log.Level]
+	replace   func(groups []string, a slog.Attr) slog.Attr
+	keys      logger.Keys
+	dupPolicy logger.DuplicateKeyPolicy
+	terminate logger.TerminateFunc
+	ctxAttrs  []logger.ContextExtractor
+	stack     slog.Leveler
+}
+
+type HandlerOptions struct {
+	AddSource       bool
+	Level           slog.Level
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps slog levels to the levels of apex/log,
+	// by default the levels below slog.LevelInfo are DebugLevel, and logger.LevelPanic is ErrorLevel.
+	LevelMap logger.LevelMap[log.Level]
+
+	// ReplaceAttr follows helper.Builtins, a time which is replaced by another kind of value or renamed is written as a field.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the source, the defaults are "file" and "func",
+	// the time, the level and the message are the fields of log.Entry, so their keys do not apply.
+	Keys logger.Keys
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+
+	// Terminate panics or exits by default, as log.Entry.Fatal does.
+	Terminate logger.TerminateFunc
+
+	ExitFunc func(code int)
+}
+
+// NewHandler passes the records to a log.Handler of apex/log, see NewHandlerFromLogger.
+func NewHandler(handler log.Handler, options *HandlerOptions) *Handler {
+	return NewHandlerFromLogger(&log.Logger{Handler: handler, Level: log.DebugLevel}, options)
+}
+
+// NewHandlerFromLogger passes the records as log.Entry values to the Handler of an existing *log.Logger,
+// the records below the Level of the logger are discarded, as the logger does.
+//
+// The attrs are the Fields of the entry, the keys in groups are prefixed by the group keys,
+// and the errors are replaced by their message, as log.Entry.WithError does.
+// The Timestamp of the entry is the time of the record, it is zero when the time of the record is zero.
+func NewHandlerFromLogger(logr *log.Logger, options *HandlerOptions) *Handler {
+	levelar := &slog.LevelVar{}
+	levelar.Set(options.Level)
+
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
+	return &Handler{
+		logr:      logr,
+		addSource: options.AddSource,
+		level:     levelar,
+		attrGroup: &helper.AttrGroup{},
+		levelMap:  levelMap,
+		replace:   options.ReplaceAttr,
+		dupPolicy: options.DuplicateKeyPolicy,
+		terminate: terminate,
+		ctxAttrs:  options.ContextExtractors,
+		stack:     options.StacktraceLevel,
+		keys:      options.Keys.WithDefaults(defaultKeys),
+	}
+}
+
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		logr:      h.logr,
+		addSource: h.addSource,
+		level:     h.level,
+		attrGroup: h.attrGroup,
+		levelMap:  h.levelMap,
+		replace:   h.replace,
+		dupPolicy: h.dupPolicy,
+		terminate: h.terminate,
+		ctxAttrs:  h.ctxAttrs,
+		stack:     h.stack,
+		keys:      h.keys,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler,
+// the level of the record in apex/log must also be at or above the Level of the logger.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		if level < override {
+			return false
+		}
+	} else if level < h.level.Level() {
+		return false
+	}
+	return h.levelMap.Map(level) >= h.logr.Level
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle methods that produce output should observe the following rules:
+//   - If r.Time is the zero time, ignore the time.
+//   - If r.PC is zero, ignore it.
+//   - Attr's values should be resolved.
+//   - If an Attr's key and value are both the zero value, ignore the Attr.
+//     This can be tested with attr.Equal(Attr{}).
+//   - If a group's key is empty, inline the group's Attrs.
+//   - If a group has no Attrs (even if it has a non-empty key),
+//     ignore it.
+//
+// The error of the log.Handler is returned.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+
+	attrs, err := helper.Normalize(h.attrGroup.WithAttrs(recordAttrs).Attrs(), h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
+
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+
+	fields := make(log.Fields, len(attrs)+3)
+	var t time.Time
+	if b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime {
+		t = b.Time.Value.Time()
+	} else if b.Time.Key != "" {
+		fields[b.Time.Key] = b.Time.Value.Any()
+	}
+
+	attrs2Fields(fields, "", attrs)
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		if h.keys.Source != "" {
+			fields[h.keys.Source+".function"] = src.Function
+			fields[h.keys.Source+".file"] = src.File
+			fields[h.keys.Source+".line"] = src.Line
+		} else {
+			fields[h.keys.File] = fmt.Sprintf("%s:%d", src.File, src.Line)
+			fields[h.keys.Func] = src.Function
+		}
+	} else if b.Source.Key != "" {
+		fields[b.Source.Key] = b.Source.Value.Any()
+	}
+
+	if h.stack != nil && b.Level >= h.stack.Level() {
+		fields[stackKey] = helper.Stack(r.PC, 1)
+	}
+
+	err = h.logr.Handler.HandleLog(&log.Entry{
+		Logger:    h.logr,
+		Fields:    fields,
+		Level:     h.levelMap.Map(b.Level),
+		Timestamp: t,
+		Message:   b.Message,
+	})
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
+	return err
+}
+
+// attrs2Fields sets normalized attrs in fields, the keys in groups are prefixed by the group keys
+func attrs2Fields(fields log.Fields, prefix string, attrs []slog.Attr) {
+	for _, attr := range helper.Flatten(nil, prefix, attrs) {
+		if err, ok := attr.Value.Any().(error); ok {
+			fields[attr.Key] = err.Error()
+		} else {
+			fields[attr.Key] = attr.Value.Any()
+		}
+	}
+}
+
+// Sync flushes the log.Handler of the logger if it has a Sync method.
+func (h *Handler) Sync() error {
+	if s, ok := h.logr.Handler.(logger.Syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Close closes the log.Handler of the logger if it has a Close method.
+func (h *Handler) Close() error {
+	if c, ok := h.logr.Handler.(logger.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	return cp
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	return cp
+}
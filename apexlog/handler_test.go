@@ -0,0 +1,351 @@
+package apexlog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/json"
+	"github.com/apex/log/handlers/logfmt"
+	"github.com/apex/log/handlers/memory"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+/*
+	This unit test is adapted from go 1.21.1 log/slog/handler_test.go
+	with slight modifications to test the apex/log handler
+*/
+
+// header is the logfmt of an entry without time, the logfmt handler of apex/log writes the fields sorted by key
+const header = "timestamp=0001-01-01T00:00:00Z level=info message=message"
+
+func TestDefaultHandle(t *testing.T) {
+	ctx := context.Background()
+	preAttrs := []slog.Attr{slog.Int("pre", 0)}
+	attrs := []slog.Attr{slog.Int("a", 1), slog.String("b", "two")}
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "no attrs",
+			want: "",
+		},
+		{
+			name:  "attrs",
+			attrs: attrs,
+			want:  " a=1 b=two",
+		},
+		{
+			name:  "preformatted",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs) },
+			attrs: attrs,
+			want:  " a=1 b=two pre=0",
+		},
+		{
+			name: "groups",
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g",
+					slog.Int("b", 2),
+					slog.Group("h", slog.Int("c", 3)),
+					slog.Int("d", 4)),
+				slog.Int("e", 5),
+			},
+			want: " a=1 e=5 g.b=2 g.d=4 g.h.c=3",
+		},
+		{
+			name:  "group",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
+			attrs: attrs,
+			want:  " pre=0 s.a=1 s.b=two",
+		},
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  " p1=1 s1.p2=2 s1.s2.a=1 s1.s2.b=two",
+		},
+		{
+			name: "two with-groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  " p1=1 s1.s2.a=1 s1.s2.b=two",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+
+			buf := &bytes.Buffer{}
+
+			var h slog.Handler = NewHandler(logfmt.New(buf), &HandlerOptions{})
+
+			if test.with != nil {
+				h = test.with(h)
+			}
+
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			got = buf.String()
+			if want := header + test.want + "\n"; got != want {
+				t.Errorf("\ngot  %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestEntry(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	_, file, line, _ := runtime.Caller(0)
+
+	mem := memory.New()
+	l := &log.Logger{Handler: mem, Level: log.InfoLevel}
+	h := NewHandlerFromLogger(l, &HandlerOptions{AddSource: true})
+	now := time.Now()
+	r := slog.NewRecord(now, slog.LevelWarn, "message", pcs[0])
+	r.AddAttrs(slog.Any("error", io.EOF), slog.Duration("d", time.Second))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	got := mem.Entries[0]
+	if got.Logger != l || !got.Timestamp.Equal(now) || got.Level != log.WarnLevel || got.Message != "message" {
+		t.Errorf("got logger %p, time %v, level %v and message %q", got.Logger, got.Timestamp, got.Level, got.Message)
+	}
+	want := fmt.Sprint(map[string]any{
+		"error": "EOF",
+		"d":     time.Second,
+		fileKey: fmt.Sprintf("%s:%d", file, line-1),
+		funcKey: "github.com/m40Jc001/slog-handler-adapter/apexlog.TestEntry",
+	})
+	if got := fmt.Sprint(map[string]any(got.Fields)); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestHandlerFromLogger(t *testing.T) {
+	mem := memory.New()
+	l := &log.Logger{Handler: mem, Level: log.WarnLevel}
+	h := NewHandlerFromLogger(l, &HandlerOptions{Level: slog.LevelDebug})
+	ctx := context.Background()
+	if h.Enabled(ctx, slog.LevelInfo) || !h.Enabled(ctx, slog.LevelWarn) {
+		t.Error("want the records below the level of the logger disabled")
+	}
+	l.Level = log.DebugLevel
+	if !h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want the level of the logger read on every record")
+	}
+
+	if err := h.Handle(ctx, slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(mem.Entries) != 1 {
+		t.Errorf("want the entry passed to the handler of the logger, got %d entries", len(mem.Entries))
+	}
+}
+
+func TestHandlerError(t *testing.T) {
+	h := NewHandler(log.HandlerFunc(func(*log.Entry) error {
+		return io.ErrShortWrite
+	}), &HandlerOptions{})
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)); err != io.ErrShortWrite {
+		t.Errorf("want the error of the apex/log handler, got %v", err)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	count := 1000
+	var buf bytes.Buffer
+	h := NewHandler(logfmt.New(&buf), &HandlerOptions{})
+	sub1 := h.WithAttrs([]slog.Attr{slog.Bool("sub1", true)})
+	sub2 := h.WithAttrs([]slog.Attr{slog.Bool("sub2", true)})
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		sub1Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub1", 0)
+		sub1Record.AddAttrs(slog.Int("i", i))
+		sub2Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub2", 0)
+		sub2Record.AddAttrs(slog.Int("i", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sub1.Handle(ctx, sub1Record); err != nil {
+				t.Error(err)
+			}
+			if err := sub2.Handle(ctx, sub2Record); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 1; i <= 2; i++ {
+		want := "hello from sub" + strconv.Itoa(i)
+		n := strings.Count(buf.String(), want)
+		if n != count {
+			t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+		}
+	}
+}
+
+// parseEntry moves the fields of an entry written by the json handler of apex/log next to its time, level and message
+func parseEntry(m map[string]any) map[string]any {
+	fields, _ := m["fields"].(map[string]any)
+	delete(m, "fields")
+	for k, v := range fields {
+		m[k] = v
+	}
+	m = conformance.Rename(m, map[string]string{"timestamp": slog.TimeKey, "message": slog.MessageKey, fileKey: slog.SourceKey})
+	// the Timestamp of log.Entry is always written, the zero time stands for a record without time, see TestZeroTime
+	if m[slog.TimeKey] == "0001-01-01T00:00:00Z" {
+		delete(m, slog.TimeKey)
+	}
+	return conformance.Unflatten(m, ".")
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Backend{
+		NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+			return NewHandler(json.New(w), &HandlerOptions{AddSource: addSource})
+		},
+		Parse: func(out []byte) ([]map[string]any, error) {
+			ms, err := conformance.ParseJSON(out)
+			for i := range ms {
+				ms[i] = parseEntry(ms[i])
+			}
+			return ms, err
+		},
+		// the json handler of apex/log does not sync nor close its writer, see TestSyncClose
+		NewHandlerWithOptions: func(w io.Writer, options conformance.Options) slog.Handler {
+			return NewHandler(json.New(w), &HandlerOptions{
+				Level:              options.Level,
+				StacktraceLevel:    options.StacktraceLevel,
+				ReplaceAttr:        options.ReplaceAttr,
+				DuplicateKeyPolicy: options.DuplicateKeyPolicy,
+				ContextExtractors:  options.ContextExtractors,
+				Terminate:          options.Terminate,
+				ExitFunc:           options.ExitFunc,
+			})
+		},
+	})
+}
+
+// TestZeroTime shows how apex/log writes a record without time, the handlers of apex/log always write the Timestamp.
+func TestZeroTime(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(json.New(buf), &HandlerOptions{})
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"fields":{},"level":"info","timestamp":"0001-01-01T00:00:00Z","message":"message"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[log.Level]
+		level    slog.Level
+		want     log.Level
+	}{
+		{name: "trace", level: logger.LevelTrace, want: log.DebugLevel},
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: log.InfoLevel},
+		{name: "panic", level: logger.LevelPanic, want: log.ErrorLevel},
+		{name: "fatal", level: logger.LevelFatal, want: log.FatalLevel},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[log.Level]{{Min: slog.LevelDebug, Level: log.DebugLevel}, {Min: slog.LevelInfo + 2, Level: log.WarnLevel}},
+			level:    slog.LevelInfo + 2,
+			want:     log.WarnLevel,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			mem := memory.New()
+			h := NewHandler(mem, &HandlerOptions{Level: logger.LevelTrace, LevelMap: test.levelMap, Terminate: logger.LogOnly})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got := mem.Entries[0].Level; got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	mem := memory.New()
+	h := NewHandler(mem, &HandlerOptions{AddSource: true, Keys: logger.Keys{Source: "source"}})
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "github.com/m40Jc001/slog-handler-adapter/apexlog.TestKeys"; mem.Entries[0].Fields["source.function"] != want {
+		t.Errorf("source: got %v, want function %s", mem.Entries[0].Fields, want)
+	}
+}
+
+// closingHandler records the calls of Sync and Close
+type closingHandler struct {
+	log.Handler
+	synced, closed int
+}
+
+func (h *closingHandler) Sync() error {
+	h.synced++
+	return nil
+}
+
+func (h *closingHandler) Close() error {
+	h.closed++
+	return nil
+}
+
+func TestSyncClose(t *testing.T) {
+	ch := &closingHandler{Handler: memory.New()}
+	h := NewHandler(ch, &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g")
+	if err := logger.Sync(h); err != nil {
+		t.Fatal(err)
+	}
+	if ch.synced != 1 || ch.closed != 0 {
+		t.Errorf("after Sync: synced %d times, closed %d times", ch.synced, ch.closed)
+	}
+	if err := logger.Close(h); err != nil {
+		t.Fatal(err)
+	}
+	if ch.synced != 1 || ch.closed != 1 {
+		t.Errorf("after Close: synced %d times, closed %d times", ch.synced, ch.closed)
+	}
+}
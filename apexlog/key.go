@@ -0,0 +1,16 @@
+package apexlog
+
+import (
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+const fileKey string = "file"
+const funcKey string = "func"
+const stackKey string = "stack"
+
+// defaultKeys are the keys of the source,
+// the time, the level and the message are the fields of log.Entry
+var defaultKeys = logger.Keys{
+	File: fileKey,
+	Func: funcKey,
+}
@@ -0,0 +1,17 @@
+package apexlog
+
+import (
+	"github.com/apex/log"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// apex/log has no level below DebugLevel, nor a panic level.
+var defaultLevelMap = logger.LevelMap[log.Level]{
+	{Min: logger.LevelTrace, Level: log.DebugLevel},
+	{Min: logger.LevelInfo, Level: log.InfoLevel},
+	{Min: logger.LevelWarn, Level: log.WarnLevel},
+	{Min: logger.LevelError, Level: log.ErrorLevel},
+	{Min: logger.LevelFatal, Level: log.FatalLevel},
+}
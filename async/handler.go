@@ -0,0 +1,314 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
+
+// ErrClosed is returned by Handle after the Handler was closed.
+var ErrClosed = errors.New("async: handler closed")
+
+// Policy decides what Handle does when the queue is full.
+type Policy int
+
+const (
+	// DropNewest drops the record passed to Handle.
+	DropNewest Policy = iota
+	// DropOldest drops the oldest record of the queue to make room for the new one.
+	DropOldest
+	// Block waits until the worker makes room in the queue.
+	Block
+	// BlockTimeout waits up to HandlerOptions.Timeout, then drops the record passed to Handle.
+	BlockTimeout
+)
+
+const defaultQueueSize = 1024
+
+type Handler struct {
+	handler slog.Handler
+	queue   *queue
+}
+
+type HandlerOptions struct {
+	// QueueSize is the number of records which can wait for the worker, 1024 by default.
+	QueueSize int
+
+	// Policy decides what Handle does when the queue is full, DropNewest by default.
+	Policy Policy
+
+	// Timeout is how long Handle waits for the queue with BlockTimeout.
+	Timeout time.Duration
+
+	// ErrorHandler is called by the worker with the errors returned by the wrapped handler,
+	// they are discarded when it is nil.
+	ErrorHandler func(err error)
+}
+
+// item is a record waiting in the queue, with the handler derived by WithAttrs and WithGroup which handles it.
+// An item with a flushed channel is queued by Sync, the worker closes the channel when it reaches it.
+type item struct {
+	ctx     context.Context
+	handler slog.Handler
+	record  slog.Record
+	flushed chan struct{}
+}
+
+// queue is shared by a Handler and the handlers derived from it
+type queue struct {
+	// mu is held for reading while an item is sent, and for writing while the channel is closed
+	mu      sync.RWMutex
+	closed  bool
+	items   chan item
+	done    chan struct{}
+	dropped atomic.Uint64
+	policy  Policy
+	timeout time.Duration
+	onError func(err error)
+	root    slog.Handler
+}
+
+// NewHandler wraps handler with a bounded queue, the records are handled by a worker goroutine,
+// so that Handle does not wait for the output of handler unless the queue is full and the Policy blocks.
+//
+// Handle copies the record with Record.Clone, so the caller may reuse it, and the context is passed
+// to the wrapped handler without its cancellation.
+// The level and the Enabled of the wrapped handler are checked by the caller, before the record is queued.
+//
+// Records at logger.LevelPanic or above are not queued: Handle waits for the queue to be drained
+// and handles them on the goroutine of the caller, so that a handler which panics or exits
+// does so after the previous records are written, and before the caller continues.
+//
+// Close drains the queue and stops the worker, it must be called before the program exits,
+// otherwise the queued records are lost.
+func NewHandler(handler slog.Handler, options *HandlerOptions) *Handler {
+	size := options.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+
+	q := &queue{
+		items:   make(chan item, size),
+		done:    make(chan struct{}),
+		policy:  options.Policy,
+		timeout: options.Timeout,
+		onError: options.ErrorHandler,
+		root:    handler,
+	}
+	go q.run()
+
+	return &Handler{
+		handler: handler,
+		queue:   q,
+	}
+}
+
+// run is the worker, it handles the queued records until the channel is closed
+func (q *queue) run() {
+	defer close(q.done)
+	for it := range q.items {
+		if it.flushed != nil {
+			close(it.flushed)
+			continue
+		}
+		if err := it.handler.Handle(it.ctx, it.record); err != nil && q.onError != nil {
+			q.onError(err)
+		}
+	}
+}
+
+// enqueue sends it according to the policy, the caller holds mu for reading
+func (q *queue) enqueue(it item) {
+	switch q.policy {
+	case Block:
+		q.items <- it
+	case BlockTimeout:
+		select {
+		case q.items <- it:
+			return
+		default:
+		}
+		timer := time.NewTimer(q.timeout)
+		defer timer.Stop()
+		select {
+		case q.items <- it:
+		case <-timer.C:
+			q.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case q.items <- it:
+				return
+			default:
+			}
+			select {
+			case old := <-q.items:
+				if old.flushed != nil {
+					// a flush is never dropped, it goes back at the end of the queue
+					q.items <- old
+					continue
+				}
+				q.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case q.items <- it:
+		default:
+			q.dropped.Add(1)
+		}
+	}
+}
+
+// flush waits until the worker handled the records queued before, the caller holds mu for reading
+func (q *queue) flush() {
+	flushed := make(chan struct{})
+	q.items <- item{flushed: flushed}
+	<-flushed
+}
+
+// Dropped returns the number of records dropped by the Policy since NewHandler,
+// it is shared by the handlers derived from this one.
+func (h *Handler) Dropped() uint64 {
+	return h.queue.dropped.Load()
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// It is the Enabled of the wrapped handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle queues a copy of the record and returns, the error of the wrapped handler
+// goes to HandlerOptions.ErrorHandler, except at logger.LevelPanic and above.
+// After Close, it returns ErrClosed, except at logger.LevelPanic and above,
+// where the record is still passed to the wrapped handler so that it terminates.
+// A record dropped by the Policy is counted by Dropped and is not an error.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	q := h.queue
+	q.mu.RLock()
+	if r.Level >= logger.LevelPanic {
+		if !q.closed {
+			q.flush()
+		}
+		q.mu.RUnlock()
+		// the wrapped handler may not return
+		return h.handler.Handle(ctx, r)
+	}
+
+	if q.closed {
+		q.mu.RUnlock()
+		return ErrClosed
+	}
+
+	q.enqueue(item{
+		ctx:     context.WithoutCancel(ctx),
+		handler: h.handler,
+		record:  r.Clone(),
+	})
+	q.mu.RUnlock()
+	return nil
+}
+
+// Sync waits until the worker handled the records queued before the call,
+// then syncs the wrapped handler with logger.Sync.
+func (h *Handler) Sync() error {
+	q := h.queue
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return ErrClosed
+	}
+	q.flush()
+	q.mu.RUnlock()
+	return logger.Sync(q.root)
+}
+
+// Close stops accepting records, waits until the worker drained the queue,
+// then closes the wrapped handler with logger.Close.
+// It closes the handlers derived from this one as well, a second Close returns ErrClosed.
+func (h *Handler) Close() error {
+	q := h.queue
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	q.closed = true
+	close(q.items)
+	q.mu.Unlock()
+
+	<-q.done
+	return logger.Close(q.root)
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{
+		handler: h.handler.WithAttrs(attrs),
+		queue:   h.queue,
+	}
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{
+		handler: h.handler.WithGroup(name),
+		queue:   h.queue,
+	}
+}
@@ -0,0 +1,370 @@
+package async
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+	"github.com/m40Jc001/slog-handler-adapter/stdlog"
+)
+
+// removeTime drops the time of slog.TextHandler, so the output can be compared
+func removeTime(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey && len(groups) == 0 {
+		return slog.Attr{}
+	}
+	return a
+}
+
+// lockedBuffer is written by the worker and read by the test
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// gateHandler reports on started when it gets a record, and handles it once release is closed
+type gateHandler struct {
+	slog.Handler
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGateHandler(w io.Writer) *gateHandler {
+	return &gateHandler{
+		Handler: slog.NewTextHandler(w, &slog.HandlerOptions{ReplaceAttr: removeTime}),
+		started: make(chan struct{}, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (h *gateHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.started <- struct{}{}
+	<-h.release
+	return h.Handler.Handle(ctx, r)
+}
+
+func info(h slog.Handler, msg string) error {
+	return h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0))
+}
+
+func TestPolicy(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		policy      Policy
+		want        string
+		wantDropped uint64
+	}{
+		{name: "drop newest", policy: DropNewest, want: "0 1 2", wantDropped: 2},
+		{name: "drop oldest", policy: DropOldest, want: "0 3 4", wantDropped: 2},
+		{name: "block timeout", policy: BlockTimeout, want: "0 1 2", wantDropped: 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &lockedBuffer{}
+			gate := newGateHandler(buf)
+			h := NewHandler(gate, &HandlerOptions{QueueSize: 2, Policy: test.policy, Timeout: 10 * time.Millisecond})
+
+			// the worker holds the first record, the two next ones fill the queue
+			_ = info(h, "0")
+			<-gate.started
+			for i := 1; i < 5; i++ {
+				if err := info(h, fmt.Sprint(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := h.Dropped(); got != test.wantDropped {
+				t.Errorf("dropped %d records, want %d", got, test.wantDropped)
+			}
+
+			close(gate.release)
+			if err := h.Close(); err != nil {
+				t.Fatal(err)
+			}
+			got := strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(buf.String(), "level=INFO msg=", ""), "\n", " "))
+			if got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPolicyBlock(t *testing.T) {
+	buf := &lockedBuffer{}
+	gate := newGateHandler(buf)
+	h := NewHandler(gate, &HandlerOptions{QueueSize: 1, Policy: Block})
+
+	_ = info(h, "0")
+	<-gate.started
+	_ = info(h, "1")
+
+	returned := make(chan struct{})
+	go func() {
+		_ = info(h, "2")
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		t.Fatal("Handle returned while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(gate.release)
+	<-returned
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if h.Dropped() != 0 {
+		t.Errorf("dropped %d records, want none", h.Dropped())
+	}
+	want := "level=INFO msg=0\nlevel=INFO msg=1\nlevel=INFO msg=2\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+// syncBuffer records the calls of Sync and Close
+type syncBuffer struct {
+	lockedBuffer
+	synced, closed int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced++
+	return nil
+}
+
+func (b *syncBuffer) Close() error {
+	b.closed++
+	return nil
+}
+
+func TestSyncClose(t *testing.T) {
+	buf := &syncBuffer{}
+	inner := stdlog.NewHandler(buf, &stdlog.HandlerOptions{})
+	h := NewHandler(inner, &HandlerOptions{QueueSize: 4, Policy: Block})
+	l := slog.New(h).With("a", 1).WithGroup("g")
+
+	for i := 0; i < 100; i++ {
+		l.Info("message", "i", i)
+	}
+	if err := logger.Sync(l.Handler()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 100 {
+		t.Errorf("after Sync: %d records written, want 100", got)
+	}
+	if buf.synced != 1 || buf.closed != 0 {
+		t.Errorf("after Sync: synced %d times, closed %d times", buf.synced, buf.closed)
+	}
+
+	for i := 0; i < 100; i++ {
+		l.Info("message", "i", i)
+	}
+	if err := logger.Close(l.Handler()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 200 {
+		t.Errorf("after Close: %d records written, want 200", got)
+	}
+	if buf.synced != 2 || buf.closed != 1 {
+		t.Errorf("after Close: synced %d times, closed %d times", buf.synced, buf.closed)
+	}
+	if !strings.Contains(buf.String(), "INFO message a=1 g.i=99\n") {
+		t.Errorf("want the attrs and the groups of the derived handler, got %s", buf.String())
+	}
+
+	if err := info(h, "message"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Handle after Close: got %v, want ErrClosed", err)
+	}
+	if err := h.Sync(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Sync after Close: got %v, want ErrClosed", err)
+	}
+	if err := h.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	buf := &lockedBuffer{}
+	gate := newGateHandler(buf)
+	h := NewHandler(gate, &HandlerOptions{})
+
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Int("b", 2), slog.Int("c", 3), slog.Int("d", 4), slog.Int("e", 5), slog.Int("f", 6))
+	_ = h.Handle(context.Background(), r)
+	// the caller still owns r
+	r.AddAttrs(slog.Int("g", 7))
+	_ = h.Handle(context.Background(), r)
+
+	close(gate.release)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "level=INFO msg=message a=1 b=2 c=3 d=4 e=5 f=6\n" +
+		"level=INFO msg=message a=1 b=2 c=3 d=4 e=5 f=6 g=7\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+type requestIDKey struct{}
+
+// contextHandler writes the request ID of the context, and whether the context is canceled
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(slog.Any("request_id", ctx.Value(requestIDKey{})), slog.Any("err", ctx.Err()))
+	return h.Handler.Handle(ctx, r)
+}
+
+func TestContext(t *testing.T) {
+	buf := &lockedBuffer{}
+	h := NewHandler(contextHandler{slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: removeTime})}, &HandlerOptions{})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), requestIDKey{}, "r1"))
+	cancel()
+	slog.New(h).InfoContext(ctx, "message")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "level=INFO msg=message request_id=r1 err=<nil>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	var errs []error
+	inner := stdlog.NewHandler(io.Discard, &stdlog.HandlerOptions{DuplicateKeyPolicy: logger.DuplicateKeyError})
+	h := NewHandler(inner, &HandlerOptions{ErrorHandler: func(err error) { errs = append(errs, err) }})
+
+	slog.New(h).Info("message", "a", 1, "a", 2)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Error() != "dup key: a" {
+		t.Errorf("want the error of the wrapped handler, got %v", errs)
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	buf := &lockedBuffer{}
+	var codes []int
+	inner := stdlog.NewHandler(buf, &stdlog.HandlerOptions{ExitFunc: func(code int) { codes = append(codes, code) }})
+	h := NewHandler(inner, &HandlerOptions{})
+	l := slog.New(h)
+	l.Info("before")
+
+	func() {
+		defer func() {
+			if r := recover(); r != "message" {
+				t.Errorf("want a panic with the message on the goroutine of the caller, got %v", r)
+			}
+		}()
+		l.Log(context.Background(), logger.LevelPanic, "message")
+	}()
+	l.Log(context.Background(), logger.LevelFatal, "message")
+	// the exit is not left to the worker
+	if len(codes) != 1 || codes[0] != 1 {
+		t.Errorf("want exit(1) before Log returns, got %v", codes)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "INFO before") ||
+		!strings.HasSuffix(lines[1], "PANIC message") || !strings.HasSuffix(lines[2], "FATAL message") {
+		t.Errorf("want the records in order, got %q", lines)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the wrapped handler still terminates after Close
+	l.Log(context.Background(), logger.LevelFatal, "after close")
+	if len(codes) != 2 {
+		t.Errorf("want exit(1) after Close, got %v", codes)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(buf.String()), "FATAL after close") {
+		t.Errorf("want the record written after Close, got %s", buf.String())
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	buf := &lockedBuffer{}
+	for _, policy := range []Policy{DropNewest, DropOldest, Block, BlockTimeout} {
+		h := NewHandler(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: removeTime}),
+			&HandlerOptions{QueueSize: 8, Policy: policy, Timeout: time.Millisecond})
+		l := slog.New(h)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					l.Info("message", "i", i, "j", j)
+					if j%10 == 0 {
+						_ = h.Sync()
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Count(buf.String(), "\n") == 0 {
+		t.Error("nothing written")
+	}
+}
+
+// syncHandler syncs after each record, so that the output can be parsed right after Handle
+type syncHandler struct {
+	*Handler
+}
+
+func (h syncHandler) Handle(ctx context.Context, r slog.Record) error {
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.Handler.Sync()
+}
+
+func (h syncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return syncHandler{h.Handler.WithAttrs(attrs).(*Handler)}
+}
+
+func (h syncHandler) WithGroup(name string) slog.Handler {
+	return syncHandler{h.Handler.WithGroup(name).(*Handler)}
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Backend{
+		NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+			return syncHandler{NewHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{AddSource: addSource}), &HandlerOptions{})}
+		},
+		Parse: conformance.ParseJSON,
+	})
+}
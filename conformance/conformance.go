@@ -0,0 +1,338 @@
+// Package conformance checks that a slog.Handler follows the rules of log/slog.
+//
+// Run executes testing/slogtest.TestHandler and a set of extra cases against a Backend.
+// A Backend only has to build its handler and parse its output back into maps,
+// the helpers in this package cover the usual output formats.
+// RunOptions checks the options shared by the handlers of this module.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+type Backend struct {
+	// NewHandler returns a handler writing to w, enabled for levels Info and above,
+	// which outputs the source of a record when addSource is true.
+	NewHandler func(w io.Writer, addSource bool) slog.Handler
+
+	// Parse turns the output of the handler into one map per record, as described by slogtest.TestHandler:
+	// groups are nested maps, and the time, level, message and source (if any) of a record
+	// are reported under slog.TimeKey, slog.LevelKey, slog.MessageKey and slog.SourceKey.
+	Parse func(out []byte) ([]map[string]any, error)
+
+	// NewHandlerWithOptions, when not nil, returns a handler writing to w with the shared options, see RunOptions.
+	NewHandlerWithOptions func(w io.Writer, options Options) slog.Handler
+
+	// OwnsWriter reports that Sync and Close of the handlers of NewHandlerWithOptions sync and close w,
+	// as the handlers of NewHandler do in most packages, RunOptions then checks them.
+	OwnsWriter bool
+}
+
+// Run runs testing/slogtest.TestHandler and the extra cases of this package against b,
+// and RunOptions when b.NewHandlerWithOptions is not nil.
+func Run(t *testing.T, b Backend) {
+	if b.NewHandlerWithOptions != nil {
+		t.Run("options", func(t *testing.T) { RunOptions(t, b) })
+	}
+
+	t.Run("slogtest", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		err := slogtest.TestHandler(b.NewHandler(buf, false), func() []map[string]any {
+			ms, err := b.Parse(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ms
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		h := b.NewHandler(io.Discard, false)
+		ctx := context.Background()
+		for level, want := range map[slog.Level]bool{
+			slog.LevelDebug: false,
+			slog.LevelInfo:  true,
+			slog.LevelWarn:  true,
+			slog.LevelError: true,
+		} {
+			if got := h.Enabled(ctx, level); got != want {
+				t.Errorf("Enabled(%s): got %t, want %t", level, got, want)
+			}
+		}
+	})
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			var h slog.Handler = b.NewHandler(buf, c.addSource)
+			if c.mod != nil {
+				h = &wrapper{h, c.mod}
+			}
+			c.f(slog.New(h))
+
+			ms, err := b.Parse(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ms) != 1 {
+				t.Fatalf("got %d results, want 1", len(ms))
+			}
+			for _, check := range c.checks {
+				if problem := check(ms[0]); problem != "" {
+					t.Errorf("%s: %s", problem, c.explanation)
+				}
+			}
+		})
+	}
+}
+
+type testCase struct {
+	name        string
+	explanation string
+	addSource   bool
+	f           func(*slog.Logger)
+	mod         func(*slog.Record)
+	checks      []check
+}
+
+var cases = []testCase{
+	{
+		name:        "empty-attr-WithAttrs",
+		explanation: "a Handler should ignore an empty Attr from the WithAttrs method",
+		f: func(l *slog.Logger) {
+			l.With("", nil).Info("msg", "k", "v")
+		},
+		checks: []check{
+			hasAttr("k", "v"),
+			missingKey(""),
+		},
+	},
+	{
+		name:        "empty-attr-in-group",
+		explanation: "a Handler should ignore an empty Attr in a group",
+		f: func(l *slog.Logger) {
+			l.Info("msg", slog.Group("G", slog.Attr{}, slog.String("c", "d")))
+		},
+		checks: []check{
+			inGroup("G", hasAttr("c", "d")),
+			inGroup("G", missingKey("")),
+		},
+	},
+	{
+		name:        "nested-empty-group",
+		explanation: "a Handler should ignore a group which only contains empty groups",
+		f: func(l *slog.Logger) {
+			l.Info("msg", "a", "b", slog.Group("G", slog.Group("H")))
+		},
+		checks: []check{
+			hasAttr("a", "b"),
+			missingKey("G"),
+		},
+	},
+	{
+		name:        "empty-group-WithAttrs",
+		explanation: "a Handler should ignore an empty group from the WithAttrs method",
+		f: func(l *slog.Logger) {
+			l.With(slog.Group("G")).Info("msg", "a", "b")
+		},
+		checks: []check{
+			hasAttr("a", "b"),
+			missingKey("G"),
+		},
+	},
+	{
+		name:        "inline-group-WithGroup",
+		explanation: "a Handler should inline the Attrs of a group with an empty key inside WithGroup",
+		f: func(l *slog.Logger) {
+			l.WithGroup("G").Info("msg", slog.Group("", slog.String("c", "d")))
+		},
+		checks: []check{
+			inGroup("G", hasAttr("c", "d")),
+		},
+	},
+	{
+		name:        "inline-group-WithAttrs",
+		explanation: "a Handler should inline the Attrs of a group with an empty key from the WithAttrs method",
+		f: func(l *slog.Logger) {
+			l.With(slog.Group("", slog.String("c", "d"))).Info("msg", "a", "b")
+		},
+		checks: []check{
+			hasAttr("a", "b"),
+			hasAttr("c", "d"),
+		},
+	},
+	{
+		name:        "inline-group-in-group",
+		explanation: "a Handler should inline the Attrs of a group with an empty key inside another group",
+		f: func(l *slog.Logger) {
+			l.Info("msg", slog.Group("G", slog.Group("", slog.String("c", "d"))))
+		},
+		checks: []check{
+			inGroup("G", hasAttr("c", "d")),
+		},
+	},
+	{
+		name:        "resolve-to-group",
+		explanation: "a Handler should output a LogValuer which resolves to a group as a group",
+		f: func(l *slog.Logger) {
+			l.Info("msg", "G", groupValuer{slog.String("a", "b")})
+		},
+		checks: []check{
+			inGroup("G", hasAttr("a", "b")),
+		},
+	},
+	{
+		name:        "resolve-WithGroup",
+		explanation: "a Handler should call Resolve on attribute values inside WithGroup",
+		f: func(l *slog.Logger) {
+			l.WithGroup("G").Info("msg", "k", replace{"replaced"})
+		},
+		checks: []check{
+			inGroup("G", hasAttr("k", "replaced")),
+		},
+	},
+	{
+		name:        "resolve-nested",
+		explanation: "a Handler should resolve a LogValuer inside a group which a LogValuer resolved to",
+		f: func(l *slog.Logger) {
+			l.Info("msg", "G", groupValuer{slog.Any("k", replace{"replaced"})})
+		},
+		checks: []check{
+			inGroup("G", hasAttr("k", "replaced")),
+		},
+	},
+	{
+		name:        "inline-resolved-group-WithAttrs",
+		explanation: "a Handler should inline a LogValuer with an empty key which resolves to a group, from the WithAttrs method",
+		f: func(l *slog.Logger) {
+			l.WithGroup("G").With(slog.Any("", groupValuer{slog.String("c", "d")})).Info("msg", "a", "b")
+		},
+		checks: []check{
+			inGroup("G", hasAttr("a", "b")),
+			inGroup("G", hasAttr("c", "d")),
+		},
+	},
+	{
+		name:        "zero-time-WithAttrs",
+		explanation: "a Handler should ignore a zero Record.Time after WithAttrs and WithGroup",
+		f: func(l *slog.Logger) {
+			l.With("a", "b").WithGroup("G").Info("msg", "k", "v")
+		},
+		mod: func(r *slog.Record) { r.Time = time.Time{} },
+		checks: []check{
+			missingKey(slog.TimeKey),
+			hasAttr("a", "b"),
+			inGroup("G", hasAttr("k", "v")),
+		},
+	},
+	{
+		name:        "source",
+		explanation: "a Handler should output SourceKey if the PC is not zero and the source is enabled",
+		addSource:   true,
+		f: func(l *slog.Logger) {
+			l.Info("msg")
+		},
+		checks: []check{
+			hasKey(slog.SourceKey),
+		},
+	},
+	{
+		name:        "empty-PC-source",
+		explanation: "a Handler should not output SourceKey if the PC is zero, even if the source is enabled",
+		addSource:   true,
+		f: func(l *slog.Logger) {
+			l.Info("msg")
+		},
+		mod: func(r *slog.Record) { r.PC = 0 },
+		checks: []check{
+			missingKey(slog.SourceKey),
+		},
+	},
+}
+
+type check func(map[string]any) string
+
+func hasKey(key string) check {
+	return func(m map[string]any) string {
+		if _, ok := m[key]; !ok {
+			return fmt.Sprintf("missing key %q", key)
+		}
+		return ""
+	}
+}
+
+func missingKey(key string) check {
+	return func(m map[string]any) string {
+		if _, ok := m[key]; ok {
+			return fmt.Sprintf("unexpected key %q", key)
+		}
+		return ""
+	}
+}
+
+func hasAttr(key string, wantVal any) check {
+	return func(m map[string]any) string {
+		if s := hasKey(key)(m); s != "" {
+			return s
+		}
+		if gotVal := m[key]; !reflect.DeepEqual(gotVal, wantVal) {
+			return fmt.Sprintf("%q: got %#v, want %#v", key, gotVal, wantVal)
+		}
+		return ""
+	}
+}
+
+func inGroup(name string, c check) check {
+	return func(m map[string]any) string {
+		v, ok := m[name]
+		if !ok {
+			return fmt.Sprintf("missing group %q", name)
+		}
+		g, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Sprintf("value for group %q is not map[string]any", name)
+		}
+		return c(g)
+	}
+}
+
+type replace struct {
+	v any
+}
+
+func (r replace) LogValue() slog.Value { return slog.AnyValue(r.v) }
+
+type groupValuer []slog.Attr
+
+func (g groupValuer) LogValue() slog.Value { return slog.GroupValue(g...) }
+
+// wrapper lets a case modify the record before it reaches the handler
+type wrapper struct {
+	slog.Handler
+	mod func(*slog.Record)
+}
+
+func (h *wrapper) Handle(ctx context.Context, r slog.Record) error {
+	h.mod(&r)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *wrapper) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &wrapper{h.Handler.WithAttrs(attrs), h.mod}
+}
+
+func (h *wrapper) WithGroup(name string) slog.Handler {
+	return &wrapper{h.Handler.WithGroup(name), h.mod}
+}
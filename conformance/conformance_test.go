@@ -0,0 +1,51 @@
+package conformance
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestStdlibJSONHandler(t *testing.T) {
+	Run(t, Backend{
+		NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+			return slog.NewJSONHandler(w, &slog.HandlerOptions{AddSource: addSource})
+		},
+		Parse: ParseJSON,
+	})
+}
+
+func TestStdlibTextHandler(t *testing.T) {
+	Run(t, Backend{
+		NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+			return slog.NewTextHandler(w, &slog.HandlerOptions{AddSource: addSource})
+		},
+		Parse: func(out []byte) ([]map[string]any, error) {
+			ms, err := ParseLogfmt(out)
+			for i := range ms {
+				ms[i] = Unflatten(ms[i], ".")
+			}
+			return ms, err
+		},
+	})
+}
+
+func TestParseLogfmtLine(t *testing.T) {
+	got, err := ParseLogfmtLine([]byte(`level=info msg="a \"quoted\" message" g.a=1 empty=""`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"level": "info", "msg": `a "quoted" message`, "g.a": "1", "empty": ""}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%q: got %#v, want %#v", k, got[k], v)
+		}
+	}
+
+	if _, err := ParseLogfmtLine([]byte(`level=info bare`)); err == nil {
+		t.Error("want an error for a key without value")
+	}
+}
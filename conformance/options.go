@@ -0,0 +1,265 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// Options are the options shared by the handlers of this module,
+// Backend.NewHandlerWithOptions sets them on the options of its package.
+type Options struct {
+	Level              slog.Level
+	StacktraceLevel    slog.Leveler
+	ReplaceAttr        func(groups []string, a slog.Attr) slog.Attr
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+	Terminate          logger.TerminateFunc
+	ExitFunc           func(code int)
+}
+
+// RunOptions checks the options shared by the handlers of this module against b.NewHandlerWithOptions,
+// with b.Parse reading the output back:
+// ReplaceAttr, DuplicateKeyPolicy, ContextExtractors with the level of logger.ContextWithLevel, Terminate with ExitFunc, StacktraceLevel,
+// and Sync and Close when b.OwnsWriter is true.
+//
+// The levels are left to the tests of each backend, as the mapping to the levels of the backends differs.
+// The cases do not use WithGroup, so that a backend whose groups are not nested maps, such as hclog, can run them.
+func RunOptions(t *testing.T, b Backend) {
+	ctx := context.Background()
+
+	parse := func(t *testing.T, out []byte, n int) []map[string]any {
+		t.Helper()
+		ms, err := b.Parse(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ms) != n {
+			t.Fatalf("got %d results, want %d", len(ms), n)
+		}
+		return ms
+	}
+
+	t.Run("replace-attr", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		h := b.NewHandlerWithOptions(buf, Options{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch {
+			case a.Key == slog.TimeKey && len(groups) == 0:
+				return slog.Int64("unix", a.Value.Time().Unix())
+			case a.Key == slog.LevelKey && len(groups) == 0:
+				return slog.Any(a.Key, slog.LevelError)
+			case a.Key == "secret":
+				return slog.Attr{}
+			case a.Key == "b":
+				return slog.String(strings.Join(append(groups, a.Key), "_"), a.Value.String())
+			}
+			return a
+		}}).WithAttrs([]slog.Attr{slog.String("secret", "hidden")})
+
+		r := slog.NewRecord(time.Unix(1697457600, 0), slog.LevelInfo, "message", 0)
+		r.AddAttrs(slog.Group("g", slog.String("b", "two"), slog.String("secret", "hidden")))
+		if err := h.Handle(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		// the level of a record at LevelError, without ReplaceAttr
+		if err := b.NewHandlerWithOptions(buf, Options{}).Handle(ctx, slog.NewRecord(time.Time{}, slog.LevelError, "message", 0)); err != nil {
+			t.Fatal(err)
+		}
+
+		ms := parse(t, buf.Bytes(), 2)
+		checkAll(t, ms[0],
+			missingKey(slog.TimeKey),
+			hasValue("unix", "1697457600"),
+			missingKey("secret"),
+			inGroup("g", hasAttr("g_b", "two")),
+			inGroup("g", missingKey("secret")),
+		)
+		if got, want := ms[0][slog.LevelKey], ms[1][slog.LevelKey]; got != want {
+			t.Errorf("level: got %v, want %v as %s", got, want, slog.LevelError)
+		}
+	})
+
+	t.Run("duplicate-key-policy", func(t *testing.T) {
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+		r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.Int("b", 1)), slog.Int("a", 2))
+
+		buf := &bytes.Buffer{}
+		h := b.NewHandlerWithOptions(buf, Options{}).WithAttrs([]slog.Attr{slog.Group("g", slog.Int("b", 0))})
+		if err := h.Handle(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		checkAll(t, parse(t, buf.Bytes(), 1)[0], hasValue("a", "2"), inGroup("g", hasValue("b", "1")))
+
+		buf.Reset()
+		h = b.NewHandlerWithOptions(buf, Options{DuplicateKeyPolicy: logger.DuplicateKeySuffix})
+		if err := h.Handle(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		checkAll(t, parse(t, buf.Bytes(), 1)[0], hasValue("a", "1"), hasValue("a#2", "2"))
+
+		buf.Reset()
+		h = b.NewHandlerWithOptions(buf, Options{DuplicateKeyPolicy: logger.DuplicateKeyError})
+		if err := h.Handle(ctx, r); err == nil || err.Error() != "dup key: a" {
+			t.Errorf("want a dup key error, got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("want no output, got %s", buf.String())
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		h := b.NewHandlerWithOptions(buf, Options{ContextExtractors: []logger.ContextExtractor{
+			func(ctx context.Context) []slog.Attr {
+				if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+					return []slog.Attr{slog.String("request_id", id), slog.Int("a", 0)}
+				}
+				return nil
+			},
+		}})
+
+		ctx := context.WithValue(ctx, requestIDKey{}, "r1")
+		if h.Enabled(ctx, slog.LevelDebug) {
+			t.Error("want debug disabled without a context level")
+		}
+		ctx = logger.ContextWithLevel(ctx, slog.LevelDebug)
+		if !h.Enabled(ctx, slog.LevelDebug) {
+			t.Error("want debug enabled by the context level")
+		}
+
+		r := slog.NewRecord(time.Time{}, slog.LevelDebug, "message", 0)
+		r.AddAttrs(slog.Int("a", 1))
+		if err := h.Handle(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		// the attrs of the context come first, the last value of a wins
+		checkAll(t, parse(t, buf.Bytes(), 1)[0], hasAttr("request_id", "r1"), hasValue("a", "1"))
+	})
+
+	t.Run("terminate", func(t *testing.T) {
+		var codes []int
+		buf := &bytes.Buffer{}
+		h := b.NewHandlerWithOptions(buf, Options{ExitFunc: func(code int) { codes = append(codes, code) }})
+
+		func() {
+			defer func() {
+				if r := recover(); r != "message" {
+					t.Errorf("want a panic with the message, got %v", r)
+				}
+			}()
+			_ = h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelPanic, "message", 0))
+		}()
+		if err := h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelFatal, "message", 0)); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(codes) != "[1]" {
+			t.Errorf("want exit(1) once, got %v", codes)
+		}
+		for _, m := range parse(t, buf.Bytes(), 2) {
+			checkAll(t, m, hasAttr(slog.MessageKey, "message"))
+		}
+	})
+
+	t.Run("stacktrace", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		logWarnAndError(slog.New(b.NewHandlerWithOptions(buf, Options{StacktraceLevel: slog.LevelError})))
+
+		ms := parse(t, buf.Bytes(), 2)
+		checkAll(t, ms[0], missingKey("stack"))
+		stack, _ := ms[1]["stack"].(string)
+		if want := "github.com/m40Jc001/slog-handler-adapter/conformance.logWarnAndError\n\t"; !strings.HasPrefix(stack, want) {
+			t.Errorf("want the stack to start at the caller, got %q", stack)
+		}
+	})
+
+	if !b.OwnsWriter {
+		return
+	}
+
+	t.Run("sync-close", func(t *testing.T) {
+		buf := &SyncBuffer{}
+		h := b.NewHandlerWithOptions(buf, Options{}).WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g")
+		if err := logger.Sync(h); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Synced != 1 || buf.Closed != 0 {
+			t.Errorf("after Sync: synced %d times, closed %d times", buf.Synced, buf.Closed)
+		}
+		if err := logger.Close(h); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Synced != 2 || buf.Closed != 1 {
+			t.Errorf("after Close: synced %d times, closed %d times", buf.Synced, buf.Closed)
+		}
+	})
+
+	t.Run("close-stderr", func(t *testing.T) {
+		if err := logger.Close(b.NewHandlerWithOptions(os.Stderr, Options{})); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stderr.Stat(); err != nil {
+			t.Errorf("want stderr left open, got %v", err)
+		}
+	})
+}
+
+// logWarnAndError logs a record at LevelWarn and one at LevelError, the stack trace starts here
+func logWarnAndError(l *slog.Logger) {
+	l.Warn("no stack")
+	l.Error("stack")
+}
+
+func checkAll(t *testing.T, m map[string]any, checks ...check) {
+	t.Helper()
+	for _, check := range checks {
+		if problem := check(m); problem != "" {
+			t.Error(problem)
+		}
+	}
+}
+
+// hasValue checks the value of key formatted as a string, as the numbers are strings in text formats and float64 in JSON
+func hasValue(key string, want string) check {
+	return func(m map[string]any) string {
+		if s := hasKey(key)(m); s != "" {
+			return s
+		}
+		var got string
+		switch v := m[key].(type) {
+		case float64:
+			got = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			got = fmt.Sprint(v)
+		}
+		if got != want {
+			return fmt.Sprintf("%q: got %s, want %s", key, got, want)
+		}
+		return ""
+	}
+}
+
+type requestIDKey struct{}
+
+// SyncBuffer is a bytes.Buffer which counts the calls of Sync and Close.
+type SyncBuffer struct {
+	bytes.Buffer
+	Synced, Closed int
+}
+
+func (b *SyncBuffer) Sync() error {
+	b.Synced++
+	return nil
+}
+
+func (b *SyncBuffer) Close() error {
+	b.Closed++
+	return nil
+}
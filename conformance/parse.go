@@ -0,0 +1,120 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseLines calls parseLine for every non-empty line of out.
+func ParseLines(out []byte, parseLine func(line []byte) (map[string]any, error)) ([]map[string]any, error) {
+	var ms []map[string]any
+	for _, line := range bytes.Split(out, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		m, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", err, line)
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// ParseJSON parses one JSON object per line.
+func ParseJSON(out []byte) ([]map[string]any, error) {
+	return ParseLines(out, ParseJSONLine)
+}
+
+// ParseJSONLine parses a single JSON object.
+func ParseJSONLine(line []byte) (map[string]any, error) {
+	var m map[string]any
+	if err := json.Unmarshal(line, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseLogfmt parses one line of space separated key=value pairs per record.
+func ParseLogfmt(out []byte) ([]map[string]any, error) {
+	return ParseLines(out, ParseLogfmtLine)
+}
+
+// ParseLogfmtLine parses space separated key=value pairs,
+// a value in double quotes is unquoted with strconv.Unquote, every value is kept as a string.
+func ParseLogfmtLine(line []byte) (map[string]any, error) {
+	m := map[string]any{}
+	s := string(line)
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return m, nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.IndexByte(s[:eq], ' ') >= 0 {
+			return nil, fmt.Errorf("missing key before %q", s)
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated value of %q", key)
+			}
+			v, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			s = s[end+1:]
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			m[key] = s[:end]
+			s = s[end:]
+		}
+	}
+}
+
+// Unflatten turns keys joined by sep, such as "G.a", into nested maps.
+func Unflatten(m map[string]any, sep string) map[string]any {
+	rt := map[string]any{}
+	for k, v := range m {
+		inner := rt
+		names := strings.Split(k, sep)
+		for _, name := range names[:len(names)-1] {
+			g, ok := inner[name].(map[string]any)
+			if !ok {
+				g = map[string]any{}
+				inner[name] = g
+			}
+			inner = g
+		}
+		inner[names[len(names)-1]] = v
+	}
+	return rt
+}
+
+// Rename moves the value of every key of m found in keys to the key it maps to,
+// such as map[string]string{"timestamp": slog.TimeKey}.
+func Rename(m map[string]any, keys map[string]string) map[string]any {
+	for from, to := range keys {
+		if v, ok := m[from]; ok {
+			delete(m, from)
+			m[to] = v
+		}
+	}
+	return m
+}
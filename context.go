@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ContextExtractor returns the attrs carried by a context, such as a request ID or a trace ID,
+// the handlers add them to each record handled with the context,
+// before the attrs of the record and in the groups of WithGroup.
+type ContextExtractor func(ctx context.Context) []slog.Attr
+
+type levelKey struct{}
+
+// ContextWithLevel returns a copy of ctx which overrides the level of the handlers,
+// their Enabled method then compares the level of a record to level instead of their own level,
+// so that a single request can be logged at debug level.
+func ContextWithLevel(ctx context.Context, level slog.Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, level)
+}
+
+// LevelFromContext returns the level set by ContextWithLevel, if any.
+func LevelFromContext(ctx context.Context) (slog.Level, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	level, ok := ctx.Value(levelKey{}).(slog.Level)
+	return level, ok
+}
@@ -0,0 +1,21 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextWithLevel(t *testing.T) {
+	_, ok := LevelFromContext(context.Background())
+	assert.False(t, ok)
+
+	_, ok = LevelFromContext(nil)
+	assert.False(t, ok)
+
+	level, ok := LevelFromContext(ContextWithLevel(context.Background(), slog.LevelDebug))
+	assert.True(t, ok)
+	assert.Equal(t, slog.LevelDebug, level)
+}
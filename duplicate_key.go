@@ -0,0 +1,19 @@
+package logger
+
+// DuplicateKeyPolicy decides what a handler writes when a key repeats within a group,
+// groups with the same key are merged and the policy applies to their attrs.
+// It is the DuplicateKeyPolicy option of the handlers, the zero value DuplicateKeyLastWins is the default.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyLastWins keeps the value of the last attr, at the position of the first one.
+	DuplicateKeyLastWins DuplicateKeyPolicy = iota
+	// DuplicateKeyFirstWins keeps the value of the first attr and drops the others.
+	DuplicateKeyFirstWins
+	// DuplicateKeySuffix keeps every attr, the n-th occurrence of a key is renamed to "key#n".
+	DuplicateKeySuffix
+	// DuplicateKeyCollect writes the values of all the attrs with the key as an array.
+	DuplicateKeyCollect
+	// DuplicateKeyError fails Handle with a "dup key" error, the record is not written.
+	DuplicateKeyError
+)
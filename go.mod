@@ -3,15 +3,28 @@ module github.com/m40Jc001/slog-handler-adapter
 go 1.20
 
 require (
+	github.com/apex/log v1.9.0
+	github.com/go-kit/log v0.2.1
+	github.com/go-logr/logr v1.4.2
+	github.com/hashicorp/go-hclog v1.6.3
+	github.com/rs/zerolog v1.33.0
 	github.com/sirupsen/logrus v1.9.3
 	github.com/stretchr/testify v1.8.4
+	go.uber.org/zap v1.26.0
+	gopkg.in/inconshreveable/log15.v2 v2.16.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/fatih/color v1.13.0 // indirect
+	github.com/go-logfmt/logfmt v0.5.1 // indirect
+	github.com/go-stack/stack v1.8.1 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	go.uber.org/zap v1.26.0 // indirect
-	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+	golang.org/x/term v0.12.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
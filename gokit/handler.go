@@ -0,0 +1,301 @@
+package gokit
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/helper"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
+
+type Handler struct {
+	logr      log.Logger
+	addSource bool
+	isJSON    bool
+	level     *slog.LevelVar
+	attrGroup *helper.AttrGroup
+	levelMap  logger.LevelMap[level.Value]
+	replace   func(groups []string, a slog.Attr) slog.Attr
+	keys      logger.Keys
+	out       io.Writer // the writer of NewHandler
+	dupPolicy logger.DuplicateKeyPolicy
+	terminate logger.TerminateFunc
+	ctxAttrs  []logger.ContextExtractor
+	stack     slog.Leveler
+}
+
+type HandlerOptions struct {
+	AddSource       bool
+	JSONFormatter   bool
+	Level           slog.Level
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps slog levels to go-kit level values,
+	// by default trace is mapped to debug, and panic and fatal to error.
+	LevelMap logger.LevelMap[level.Value]
+
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the built-in attributes,
+	// the level is always written under level.Key(), which level.NewFilter reads, so Keys.Level does not apply,
+	// the defaults are "ts", "msg", "caller" and "func".
+	Keys logger.Keys
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+	Terminate          logger.TerminateFunc
+	ExitFunc           func(code int)
+}
+
+// NewHandler writes logfmt, or JSON when JSONFormatter is true, with the loggers of go-kit/log.
+// The writer is wrapped with log.NewSyncWriter, as go-kit does not lock it.
+func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
+	w := log.NewSyncWriter(writer)
+	var logr log.Logger
+	if options.JSONFormatter {
+		logr = log.NewJSONLogger(w)
+	} else {
+		logr = log.NewLogfmtLogger(w)
+	}
+	h := NewHandlerFromLogger(logr, options)
+	h.out = writer
+	return h
+}
+
+// NewHandlerFromLogger wraps an existing log.Logger, such as a logger with bound keyvals or a level.NewFilter.
+// A filter applies after the level of the handler, to the go-kit level value of each record.
+// The keyvals of the record are passed in a single Log call:
+// the time, the level, the message, the attrs, the source and the stack.
+// JSONFormatter only selects how groups are converted: nested maps for a JSON logger or dotted keys.
+func NewHandlerFromLogger(logr log.Logger, options *HandlerOptions) *Handler {
+	levelar := &slog.LevelVar{}
+	levelar.Set(options.Level)
+
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
+	return &Handler{
+		logr:      logr,
+		addSource: options.AddSource,
+		level:     levelar,
+		attrGroup: &helper.AttrGroup{},
+		levelMap:  levelMap,
+		replace:   options.ReplaceAttr,
+		dupPolicy: options.DuplicateKeyPolicy,
+		terminate: terminate,
+		ctxAttrs:  options.ContextExtractors,
+		stack:     options.StacktraceLevel,
+		keys:      options.Keys.WithDefaults(defaultKeys),
+		isJSON:    options.JSONFormatter,
+	}
+}
+
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		logr:      h.logr,
+		addSource: h.addSource,
+		level:     h.level,
+		isJSON:    h.isJSON,
+		attrGroup: h.attrGroup,
+		levelMap:  h.levelMap,
+		replace:   h.replace,
+		dupPolicy: h.dupPolicy,
+		terminate: h.terminate,
+		ctxAttrs:  h.ctxAttrs,
+		stack:     h.stack,
+		keys:      h.keys,
+		out:       h.out,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		return level >= override
+	}
+	return level >= h.level.Level()
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle methods that produce output should observe the following rules:
+//   - If r.Time is the zero time, ignore the time.
+//   - If r.PC is zero, ignore it.
+//   - Attr's values should be resolved.
+//   - If an Attr's key and value are both the zero value, ignore the Attr.
+//     This can be tested with attr.Equal(Attr{}).
+//   - If a group's key is empty, inline the group's Attrs.
+//   - If a group has no Attrs (even if it has a non-empty key),
+//     ignore it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+
+	attrs, err := helper.Normalize(h.attrGroup.WithAttrs(recordAttrs).Attrs(), h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
+
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+
+	keyvals := make([]any, 0, 2*len(attrs)+12)
+	switch {
+	case b.Time.Key == slog.TimeKey:
+		keyvals = append(keyvals, h.keys.Time, b.Time.Value.Any())
+	case b.Time.Key != "":
+		keyvals = append(keyvals, b.Time.Key, b.Time.Value.Any())
+	}
+	keyvals = append(keyvals, level.Key(), h.levelMap.Map(b.Level), h.keys.Message, b.Message)
+
+	if h.isJSON {
+		keyvals = attrs2JSONKeyvals(keyvals, attrs)
+	} else {
+		keyvals = attrs2TextKeyvals(keyvals, "", attrs)
+	}
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		switch {
+		case h.keys.Source != "" && h.isJSON:
+			keyvals = append(keyvals, h.keys.Source, map[string]any{"function": src.Function, "file": src.File, "line": src.Line})
+		case h.keys.Source != "":
+			keyvals = append(keyvals,
+				h.keys.Source+".function", src.Function,
+				h.keys.Source+".file", src.File,
+				h.keys.Source+".line", src.Line)
+		default:
+			keyvals = append(keyvals, h.keys.File, fmt.Sprintf("%s:%d", src.File, src.Line), h.keys.Func, src.Function)
+		}
+	} else if b.Source.Key != "" {
+		keyvals = append(keyvals, b.Source.Key, b.Source.Value.Any())
+	}
+
+	if h.stack != nil && b.Level >= h.stack.Level() {
+		keyvals = append(keyvals, stackKey, helper.Stack(r.PC, 1))
+	}
+
+	err = h.logr.Log(keyvals...)
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
+	return err
+}
+
+// attrs2TextKeyvals appends normalized attrs to keyvals, the keys in groups are prefixed by the group keys
+func attrs2TextKeyvals(keyvals []any, prefix string, attrs []slog.Attr) []any {
+	for _, attr := range helper.Flatten(nil, prefix, attrs) {
+		keyvals = append(keyvals, attr.Key, textValue(attr.Value))
+	}
+	return keyvals
+}
+
+// textValue returns the value as accepted by logfmt,
+// which rejects slices, maps and structs, they are formatted like slog.TextHandler does
+func textValue(v slog.Value) any {
+	if v.Kind() != slog.KindAny {
+		return v.Any()
+	}
+	switch x := v.Any().(type) {
+	case nil, error, fmt.Stringer, encoding.TextMarshaler:
+		return x
+	case []byte:
+		return string(x)
+	default:
+		return fmt.Sprintf("%+v", x)
+	}
+}
+
+// attrs2JSONKeyvals appends normalized attrs to keyvals, groups become nested maps
+func attrs2JSONKeyvals(keyvals []any, attrs []slog.Attr) []any {
+	for _, attr := range attrs {
+		keyvals = append(keyvals, attr.Key, helper.NestedValue(attr.Value))
+	}
+	return keyvals
+}
+
+// Sync flushes the writer of NewHandler, see logger.SyncWriter.
+func (h *Handler) Sync() error {
+	return logger.SyncWriter(h.out)
+}
+
+// Close syncs the writer of NewHandler and closes it, see logger.CloseWriter,
+// the logger of NewHandlerFromLogger is owned by the caller.
+func (h *Handler) Close() error {
+	return errors.Join(h.Sync(), logger.CloseWriter(h.out))
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	return cp
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	return cp
+}
@@ -0,0 +1,335 @@
+package gokit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+/*
+	This unit test is adapted from go 1.21.1 log/slog/handler_test.go
+	with slight modifications to test the go-kit handler
+*/
+
+func TestDefaultHandle(t *testing.T) {
+	ctx := context.Background()
+	preAttrs := []slog.Attr{slog.Int("pre", 0)}
+	attrs := []slog.Attr{slog.Int("a", 1), slog.String("b", "two")}
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "no attrs",
+			want: "level=info msg=message\n",
+		},
+		{
+			name:  "attrs",
+			attrs: attrs,
+			want:  "level=info msg=message a=1 b=two\n",
+		},
+		{
+			name:  "preformatted",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs) },
+			attrs: attrs,
+			want:  "level=info msg=message pre=0 a=1 b=two\n",
+		},
+		{
+			name: "groups",
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g",
+					slog.Int("b", 2),
+					slog.Group("h", slog.Int("c", 3)),
+					slog.Int("d", 4)),
+				slog.Int("e", 5),
+			},
+			want: "level=info msg=message a=1 g.b=2 g.h.c=3 g.d=4 e=5\n",
+		},
+		{
+			name:  "group",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
+			attrs: attrs,
+			want:  "level=info msg=message pre=0 s.a=1 s.b=two\n",
+		},
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  "level=info msg=message p1=1 s1.p2=2 s1.s2.a=1 s1.s2.b=two\n",
+		},
+		{
+			name: "two with-groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  "level=info msg=message p1=1 s1.s2.a=1 s1.s2.b=two\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+
+			buf := &bytes.Buffer{}
+
+			var h slog.Handler = NewHandler(buf, &HandlerOptions{})
+
+			if test.with != nil {
+				h = test.with(h)
+			}
+
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			got = buf.String()
+			if got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJSONHandle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{JSONFormatter: true}).
+		WithAttrs([]slog.Attr{slog.Int("pre", 0)}).
+		WithGroup("s")
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelWarn, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.String("b", "two")))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"level":"warn","msg":"message","pre":0,"s":{"a":1,"g":{"b":"two"}},"ts":"2023-10-16T12:00:00Z"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestTextValues(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "message", 0)
+	r.AddAttrs(
+		slog.Duration("d", time.Second),
+		slog.Any("list", []int{1, 2}),
+		slog.Any("bytes", []byte("raw")),
+		slog.Any("err", io.EOF),
+	)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := "ts=2023-10-16T12:00:00Z level=info msg=message d=1s list=\"[1 2]\" bytes=raw err=EOF\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestHandlerFromLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logr := log.With(log.NewLogfmtLogger(buf), "service", "app")
+	logr = level.NewFilter(logr, level.AllowWarn())
+
+	// the filter reads the level under level.Key(), whatever the keys of the handler
+	h := NewHandlerFromLogger(logr, &HandlerOptions{Level: slog.LevelDebug, Keys: logger.Keys{Level: "severity"}}).WithGroup("s")
+	for _, lvl := range []slog.Level{slog.LevelInfo, slog.LevelError} {
+		r := slog.NewRecord(time.Time{}, lvl, "message", 0)
+		r.AddAttrs(slog.Int("a", 1))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := "service=app level=error msg=message s.a=1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	count := 1000
+	for _, handlerType := range []string{"text", "json"} {
+		t.Run(handlerType, func(t *testing.T) {
+			var buf bytes.Buffer
+			var h slog.Handler
+			switch handlerType {
+			case "text":
+				h = NewHandler(&buf, &HandlerOptions{})
+			case "json":
+				h = NewHandler(&buf, &HandlerOptions{JSONFormatter: true})
+			default:
+				t.Fatalf("unexpected handlerType %q", handlerType)
+			}
+			sub1 := h.WithAttrs([]slog.Attr{slog.Bool("sub1", true)})
+			sub2 := h.WithAttrs([]slog.Attr{slog.Bool("sub2", true)})
+			var wg sync.WaitGroup
+			for i := 0; i < count; i++ {
+				sub1Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub1", 0)
+				sub1Record.AddAttrs(slog.Int("i", i))
+				sub2Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub2", 0)
+				sub2Record.AddAttrs(slog.Int("i", i))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := sub1.Handle(ctx, sub1Record); err != nil {
+						t.Error(err)
+					}
+					if err := sub2.Handle(ctx, sub2Record); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			for i := 1; i <= 2; i++ {
+				want := "hello from sub" + strconv.Itoa(i)
+				n := strings.Count(buf.String(), want)
+				if n != count {
+					t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+				}
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	keys := map[string]string{timeKey: slog.TimeKey, msgKey: slog.MessageKey, callerKey: slog.SourceKey}
+	for _, isJSON := range []bool{false, true} {
+		isJSON := isJSON
+		parse := conformance.ParseLogfmt
+		if isJSON {
+			parse = conformance.ParseJSON
+		}
+		t.Run(fmt.Sprintf("json=%t", isJSON), func(t *testing.T) {
+			conformance.Run(t, conformance.Backend{
+				NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+					return NewHandler(w, &HandlerOptions{AddSource: addSource, JSONFormatter: isJSON})
+				},
+				Parse: func(out []byte) ([]map[string]any, error) {
+					ms, err := parse(out)
+					for i := range ms {
+						ms[i] = conformance.Rename(ms[i], keys)
+						if !isJSON {
+							ms[i] = conformance.Unflatten(ms[i], ".")
+						}
+					}
+					return ms, err
+				},
+				NewHandlerWithOptions: func(w io.Writer, options conformance.Options) slog.Handler {
+					return NewHandler(w, &HandlerOptions{
+						JSONFormatter:      isJSON,
+						Level:              options.Level,
+						StacktraceLevel:    options.StacktraceLevel,
+						ReplaceAttr:        options.ReplaceAttr,
+						DuplicateKeyPolicy: options.DuplicateKeyPolicy,
+						ContextExtractors:  options.ContextExtractors,
+						Terminate:          options.Terminate,
+						ExitFunc:           options.ExitFunc,
+					})
+				},
+				OwnsWriter: true,
+			})
+		})
+	}
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[level.Value]
+		level    slog.Level
+		want     string
+	}{
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: "level=info msg=message\n"},
+		{name: "trace", level: logger.LevelTrace, want: "level=debug msg=message\n"},
+		{name: "fatal", level: logger.LevelFatal, want: "level=error msg=message\n"},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[level.Value]{{Min: slog.LevelDebug, Level: level.DebugValue()}, {Min: slog.LevelInfo + 2, Level: level.WarnValue()}},
+			level:    slog.LevelInfo + 2,
+			want:     "level=warn msg=message\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(buf, &HandlerOptions{Level: logger.LevelTrace, LevelMap: test.levelMap, Terminate: logger.LogOnly})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{AddSource: true, JSONFormatter: true, Keys: logger.Keys{
+		Time:    "@timestamp",
+		Level:   "severity",
+		Message: "message",
+		Source:  "source",
+	}})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ms[0]
+	for key, want := range map[string]any{"@timestamp": "2023-10-16T12:00:00Z", "level": "info", "message": "hello"} {
+		if got[key] != want {
+			t.Errorf("%q: got %v, want %v", key, got[key], want)
+		}
+	}
+	if _, ok := got["severity"]; ok {
+		t.Errorf("want the level under level.Key() only, got %v", got)
+	}
+	src, _ := got["source"].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/gokit.TestKeys"; src["function"] != want {
+		t.Errorf("source: got %v, want function %s", got["source"], want)
+	}
+}
+
+func TestCloseFromLogger(t *testing.T) {
+	buf := &conformance.SyncBuffer{}
+	if err := logger.Close(NewHandlerFromLogger(log.NewLogfmtLogger(buf), &HandlerOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Synced != 0 || buf.Closed != 0 {
+		t.Errorf("want the writer of the caller untouched, synced %d times, closed %d times", buf.Synced, buf.Closed)
+	}
+}
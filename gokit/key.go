@@ -0,0 +1,20 @@
+package gokit
+
+import (
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+const timeKey string = "ts"
+const msgKey string = "msg"
+const callerKey string = "caller"
+const funcKey string = "func"
+const stackKey string = "stack"
+
+// defaultKeys follows the conventions of go-kit/log: log.DefaultTimestamp and log.DefaultCaller are
+// usually bound to "ts" and "caller", the level is always written under level.Key()
+var defaultKeys = logger.Keys{
+	Time:    timeKey,
+	Message: msgKey,
+	File:    callerKey,
+	Func:    funcKey,
+}
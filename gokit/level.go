@@ -0,0 +1,16 @@
+package gokit
+
+import (
+	"github.com/go-kit/log/level"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// go-kit has no trace, panic and fatal levels, they are mapped to the nearest level.
+var defaultLevelMap = logger.LevelMap[level.Value]{
+	{Min: logger.LevelTrace, Level: level.DebugValue()},
+	{Min: logger.LevelInfo, Level: level.InfoValue()},
+	{Min: logger.LevelWarn, Level: level.WarnValue()},
+	{Min: logger.LevelError, Level: level.ErrorValue()},
+}
@@ -0,0 +1,313 @@
+package hclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/hashicorp/go-hclog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/helper"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
+
+type Handler struct {
+	logr       hclog.Logger
+	addSource  bool
+	addTime    bool // false for NewHandlerFromLogger, whose logger writes its own time
+	isJSON     bool
+	level      *slog.LevelVar
+	scope      *helper.Scope
+	levelMap   logger.LevelMap[hclog.Level]
+	replace    func(groups []string, a slog.Attr) slog.Attr
+	keys       logger.Keys
+	timeFormat string
+	out        io.Writer // the writer of NewHandler
+	dupPolicy  logger.DuplicateKeyPolicy
+	terminate  logger.TerminateFunc
+	ctxAttrs   []logger.ContextExtractor
+	stack      slog.Leveler
+}
+
+type HandlerOptions struct {
+	AddSource       bool
+	JSONFormatter   bool
+	Level           slog.Level
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps slog levels to hclog levels,
+	// by default the levels between two constants of the root package are mapped to the lower one,
+	// and logger.LevelPanic and logger.LevelFatal to hclog.Error.
+	LevelMap logger.LevelMap[hclog.Level]
+
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the built-in attributes,
+	// the defaults are the keys of the JSON output of hclog: "@timestamp" and "@caller", and "func".
+	// hclog writes the level and the message itself, so their keys do not apply.
+	Keys logger.Keys
+
+	// DuplicateKeyPolicy applies to the attrs of a record,
+	// the attrs of WithAttrs are passed to hclog.Logger.With, which keeps the last value of a key.
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+
+	ContextExtractors []logger.ContextExtractor
+	Terminate         logger.TerminateFunc
+	ExitFunc          func(code int)
+}
+
+// NewHandler writes the format of hclog, or its JSON format when JSONFormatter is true.
+// The time of hclog is disabled, the time of the record is written as a field,
+// in the time format of hclog, so that go-plugin still parses the JSON output.
+func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
+	timeFormat := hclog.TimeFormat
+	if options.JSONFormatter {
+		timeFormat = hclog.TimeFormatJSON
+	}
+	logr := hclog.New(&hclog.LoggerOptions{
+		Level:       hclog.Trace, // control by "Enable" function
+		Output:      writer,
+		JSONFormat:  options.JSONFormatter,
+		DisableTime: true,
+	})
+
+	h := newHandler(logr, options)
+	h.addTime = true
+	h.timeFormat = timeFormat
+	h.out = writer
+	return h
+}
+
+// NewHandlerFromLogger wraps an existing hclog.Logger and keeps its output, format, name and implied args.
+//
+// The logger writes its own time, so the time of the record is not written.
+// Its level is not modified, as it may be shared with the other loggers of a plugin,
+// a record is written only when both Level and the level of the logger enable it.
+// JSONFormatter only selects how groups in attrs are converted: nested maps or dotted keys.
+func NewHandlerFromLogger(logr hclog.Logger, options *HandlerOptions) *Handler {
+	return newHandler(logr, options)
+}
+
+func newHandler(logr hclog.Logger, options *HandlerOptions) *Handler {
+	levelar := &slog.LevelVar{}
+	levelar.Set(options.Level)
+
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
+	return &Handler{
+		logr:      logr,
+		addSource: options.AddSource,
+		level:     levelar,
+		scope:     helper.NewScope(),
+		levelMap:  levelMap,
+		replace:   options.ReplaceAttr,
+		dupPolicy: options.DuplicateKeyPolicy,
+		terminate: terminate,
+		ctxAttrs:  options.ContextExtractors,
+		stack:     options.StacktraceLevel,
+		keys:      options.Keys.WithDefaults(defaultKeys),
+		isJSON:    options.JSONFormatter,
+	}
+}
+
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		logr:       h.logr,
+		addSource:  h.addSource,
+		addTime:    h.addTime,
+		level:      h.level,
+		isJSON:     h.isJSON,
+		scope:      h.scope,
+		levelMap:   h.levelMap,
+		replace:    h.replace,
+		dupPolicy:  h.dupPolicy,
+		terminate:  h.terminate,
+		ctxAttrs:   h.ctxAttrs,
+		stack:      h.stack,
+		keys:       h.keys,
+		timeFormat: h.timeFormat,
+		out:        h.out,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler,
+// the level of the hclog logger still applies.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.levelMap.Map(level) < h.logr.GetLevel() {
+		return false
+	}
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		return level >= override
+	}
+	return level >= h.level.Level()
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle methods that produce output should observe the following rules:
+//   - If r.Time is the zero time, ignore the time.
+//   - If r.PC is zero, ignore it.
+//   - Attr's values should be resolved.
+//   - If an Attr's key and value are both the zero value, ignore the Attr.
+//     This can be tested with attr.Equal(Attr{}).
+//   - If a group's key is empty, inline the group's Attrs.
+//   - If a group has no Attrs (even if it has a non-empty key),
+//     ignore it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+
+	attrs, err := h.scope.Normalize(recordAttrs, h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
+
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+
+	args := make([]any, 0, 2*len(attrs)+8)
+	switch {
+	case b.Time.Key == slog.TimeKey && !h.addTime:
+	case b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime:
+		args = append(args, h.keys.Time, b.Time.Value.Time().Format(h.timeFormat))
+	case b.Time.Key == slog.TimeKey:
+		args = append(args, h.keys.Time, b.Time.Value.Any())
+	case b.Time.Key != "":
+		args = append(args, b.Time.Key, b.Time.Value.Any())
+	}
+
+	args = h.appendAttrs(args, attrs)
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		switch {
+		case h.keys.Source != "" && h.isJSON:
+			args = append(args, h.keys.Source, map[string]any{"function": src.Function, "file": src.File, "line": src.Line})
+		case h.keys.Source != "":
+			args = append(args,
+				h.keys.Source+".function", src.Function,
+				h.keys.Source+".file", src.File,
+				h.keys.Source+".line", src.Line)
+		default:
+			args = append(args, h.keys.File, fmt.Sprintf("%s:%d", src.File, src.Line), h.keys.Func, src.Function)
+		}
+	} else if b.Source.Key != "" {
+		args = append(args, b.Source.Key, b.Source.Value.Any())
+	}
+
+	if h.stack != nil && b.Level >= h.stack.Level() {
+		args = append(args, stackKey, helper.Stack(r.PC, 1))
+	}
+
+	h.logr.Log(h.levelMap.Map(b.Level), b.Message, args...)
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
+	return nil
+}
+
+// appendAttrs appends normalized attrs to args, as nested maps in JSON and as dotted keys in text
+func (h *Handler) appendAttrs(args []any, attrs []slog.Attr) []any {
+	if h.isJSON {
+		return attrs2JSONArgs(args, attrs)
+	}
+	return attrs2TextArgs(args, "", attrs)
+}
+
+// attrs2TextArgs appends normalized attrs to args, the keys in groups are prefixed by the group keys
+func attrs2TextArgs(args []any, prefix string, attrs []slog.Attr) []any {
+	for _, attr := range helper.Flatten(nil, prefix, attrs) {
+		args = append(args, attr.Key, attr.Value.Any())
+	}
+	return args
+}
+
+// attrs2JSONArgs appends normalized attrs to args, groups become nested maps
+func attrs2JSONArgs(args []any, attrs []slog.Attr) []any {
+	for _, attr := range attrs {
+		args = append(args, attr.Key, helper.NestedValue(attr.Value))
+	}
+	return args
+}
+
+// Sync flushes the writer of NewHandler, see logger.SyncWriter.
+func (h *Handler) Sync() error {
+	return logger.SyncWriter(h.out)
+}
+
+// Close syncs the writer of NewHandler and closes it, see logger.CloseWriter,
+// the logger of NewHandlerFromLogger is owned by the caller.
+func (h *Handler) Close() error {
+	return errors.Join(h.Sync(), logger.CloseWriter(h.out))
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+//
+// The attrs are passed to hclog.Logger.With, which sorts the implied args by key.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := h.clone()
+	cp.scope, attrs = cp.scope.WithAttrs(attrs, h.replace)
+	if args := cp.appendAttrs(nil, attrs); len(args) > 0 {
+		cp.logr = cp.logr.With(args...)
+	}
+	return cp
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+//
+// The group is the name of a hclog.Logger.Named sub-logger, which prefixes the message in text
+// and is written under the "@module" key in JSON, the names are joined by ".".
+// So unlike the other handlers, the keys of the attrs are not qualified by the groups,
+// and a group without attrs is still written as the name.
+// The groups are passed to ReplaceAttr as for the other handlers.
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.scope = cp.scope.WithGroup(name)
+	cp.logr = cp.logr.Named(name)
+	return cp
+}
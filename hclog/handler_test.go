@@ -0,0 +1,361 @@
+package hclog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+/*
+	This unit test is adapted from go 1.21.1 log/slog/handler_test.go
+	with slight modifications to test the hclog handler
+*/
+
+func TestDefaultHandle(t *testing.T) {
+	ctx := context.Background()
+	preAttrs := []slog.Attr{slog.Int("pre", 0)}
+	attrs := []slog.Attr{slog.Int("a", 1), slog.String("b", "two")}
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "no attrs",
+			want: "[INFO]  message\n",
+		},
+		{
+			name:  "attrs",
+			attrs: attrs,
+			want:  "[INFO]  message: a=1 b=two\n",
+		},
+		{
+			name:  "preformatted",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs) },
+			attrs: attrs,
+			want:  "[INFO]  message: pre=0 a=1 b=two\n",
+		},
+		{
+			name: "groups",
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g",
+					slog.Int("b", 2),
+					slog.Group("h", slog.Int("c", 3)),
+					slog.Int("d", 4)),
+				slog.Int("e", 5),
+			},
+			want: "[INFO]  message: a=1 g.b=2 g.h.c=3 g.d=4 e=5\n",
+		},
+		{
+			name:  "group",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
+			attrs: attrs,
+			want:  "[INFO]  s: message: pre=0 a=1 b=two\n",
+		},
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  "[INFO]  s1.s2: message: p1=1 p2=2 a=1 b=two\n",
+		},
+		{
+			name: "two with-groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  "[INFO]  s1.s2: message: p1=1 a=1 b=two\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+
+			buf := &bytes.Buffer{}
+
+			var h slog.Handler = NewHandler(buf, &HandlerOptions{})
+
+			if test.with != nil {
+				h = test.with(h)
+			}
+
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			got = buf.String()
+			if got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJSONHandle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{JSONFormatter: true}).
+		WithAttrs([]slog.Attr{slog.Int("pre", 0)}).
+		WithGroup("s")
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelWarn, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.String("b", "two")))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"@level":"warn","@message":"message","@module":"s","@timestamp":"2023-10-16T12:00:00.000000Z","a":1,"g":{"b":"two"},"pre":0}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestHandlerFromLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logr := hclog.New(&hclog.LoggerOptions{
+		Name:        "plugin",
+		Level:       hclog.Warn,
+		Output:      buf,
+		DisableTime: true,
+	}).With("service", "app")
+
+	h := NewHandlerFromLogger(logr, &HandlerOptions{Level: slog.LevelDebug}).WithGroup("s")
+	// the level of the logger is shared with the other loggers of the plugin, it is left alone
+	if got := logr.GetLevel(); got != hclog.Warn {
+		t.Errorf("the level of the logger was set to %s", got)
+	}
+	if h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("want info disabled by the level of the logger")
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelWarn, "message", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[WARN]  plugin.s: message: service=app a=1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	count := 1000
+	for _, handlerType := range []string{"text", "json"} {
+		t.Run(handlerType, func(t *testing.T) {
+			var buf bytes.Buffer
+			var h slog.Handler
+			switch handlerType {
+			case "text":
+				h = NewHandler(&buf, &HandlerOptions{})
+			case "json":
+				h = NewHandler(&buf, &HandlerOptions{JSONFormatter: true})
+			default:
+				t.Fatalf("unexpected handlerType %q", handlerType)
+			}
+			sub1 := h.WithAttrs([]slog.Attr{slog.Bool("sub1", true)})
+			sub2 := h.WithAttrs([]slog.Attr{slog.Bool("sub2", true)})
+			var wg sync.WaitGroup
+			for i := 0; i < count; i++ {
+				sub1Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub1", 0)
+				sub1Record.AddAttrs(slog.Int("i", i))
+				sub2Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub2", 0)
+				sub2Record.AddAttrs(slog.Int("i", i))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := sub1.Handle(ctx, sub1Record); err != nil {
+						t.Error(err)
+					}
+					if err := sub2.Handle(ctx, sub2Record); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			for i := 1; i <= 2; i++ {
+				want := "hello from sub" + strconv.Itoa(i)
+				n := strings.Count(buf.String(), want)
+				if n != count {
+					t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+				}
+			}
+		})
+	}
+}
+
+// TestGroups covers the cases of testing/slogtest which hclog does not pass by design,
+// so the handler only runs conformance.RunOptions, see TestOptions:
+// a group is the name of a sub-logger, the keys of the attrs are not qualified by it,
+// and the name is written even when the group has no attrs.
+func TestGroups(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name string
+		f    func(l *slog.Logger)
+		want string
+	}{
+		{
+			name: "multi-With",
+			f: func(l *slog.Logger) {
+				l.With("a", "b").WithGroup("G").With("c", "d").WithGroup("H").InfoContext(ctx, "msg", "e", "f")
+			},
+			want: "[INFO]  G.H: msg: a=b c=d e=f\n",
+		},
+		{
+			name: "WithAttrs then WithGroup",
+			f: func(l *slog.Logger) {
+				l.With("a", "b").WithGroup("G").InfoContext(ctx, "msg", "k", "v")
+			},
+			want: "[INFO]  G: msg: a=b k=v\n",
+		},
+		{
+			name: "empty group",
+			f: func(l *slog.Logger) {
+				l.With("a", "b").WithGroup("G").With("c", "d").WithGroup("H").InfoContext(ctx, "msg")
+			},
+			want: "[INFO]  G.H: msg: a=b c=d\n",
+		},
+		{
+			name: "nested empty groups",
+			f: func(l *slog.Logger) {
+				l.WithGroup("G").WithGroup("H").InfoContext(ctx, "msg")
+			},
+			want: "[INFO]  G.H: msg\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			test.f(slog.New(zeroTime{NewHandler(buf, &HandlerOptions{})}))
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+// zeroTime removes the time of the records, so that the output can be compared
+type zeroTime struct {
+	slog.Handler
+}
+
+func (h zeroTime) Handle(ctx context.Context, r slog.Record) error {
+	r.Time = time.Time{}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h zeroTime) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return zeroTime{h.Handler.WithAttrs(attrs)}
+}
+
+func (h zeroTime) WithGroup(name string) slog.Handler {
+	return zeroTime{h.Handler.WithGroup(name)}
+}
+
+// TestOptions runs the cases of conformance.RunOptions on the JSON output,
+// which do not use WithGroup, see TestGroups
+func TestOptions(t *testing.T) {
+	keys := map[string]string{"@timestamp": slog.TimeKey, "@level": slog.LevelKey, "@message": slog.MessageKey}
+	conformance.RunOptions(t, conformance.Backend{
+		Parse: func(out []byte) ([]map[string]any, error) {
+			ms, err := conformance.ParseJSON(out)
+			for i := range ms {
+				ms[i] = conformance.Rename(ms[i], keys)
+			}
+			return ms, err
+		},
+		NewHandlerWithOptions: func(w io.Writer, options conformance.Options) slog.Handler {
+			return NewHandler(w, &HandlerOptions{
+				JSONFormatter:      true,
+				Level:              options.Level,
+				StacktraceLevel:    options.StacktraceLevel,
+				ReplaceAttr:        options.ReplaceAttr,
+				DuplicateKeyPolicy: options.DuplicateKeyPolicy,
+				ContextExtractors:  options.ContextExtractors,
+				Terminate:          options.Terminate,
+				ExitFunc:           options.ExitFunc,
+			})
+		},
+		OwnsWriter: true,
+	})
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[hclog.Level]
+		level    slog.Level
+		want     string
+	}{
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: "[INFO]  message\n"},
+		{name: "trace", level: logger.LevelTrace, want: "[TRACE] message\n"},
+		{name: "fatal", level: logger.LevelFatal, want: "[ERROR] message\n"},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[hclog.Level]{{Min: slog.LevelDebug, Level: hclog.Debug}, {Min: slog.LevelInfo + 2, Level: hclog.Warn}},
+			level:    slog.LevelInfo + 2,
+			want:     "[WARN]  message\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(buf, &HandlerOptions{Level: logger.LevelTrace, LevelMap: test.levelMap, Terminate: logger.LogOnly})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{AddSource: true, JSONFormatter: true, Keys: logger.Keys{
+		Time:   "time",
+		Source: "source",
+	}})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ms[0]
+	if want := "2023-10-16T12:00:00.000000Z"; got["time"] != want {
+		t.Errorf("time: got %v, want %v", got["time"], want)
+	}
+	src, _ := got["source"].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/hclog.TestKeys"; src["function"] != want {
+		t.Errorf("source: got %v, want function %s", got["source"], want)
+	}
+}
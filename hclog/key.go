@@ -0,0 +1,23 @@
+package hclog
+
+import (
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// the keys of the JSON output of hclog, which go-plugin parses
+const timeKey string = "@timestamp"
+const levelKey string = "@level"
+const msgKey string = "@message"
+const moduleKey string = "@module"
+const callerKey string = "@caller"
+const funcKey string = "func"
+const stackKey string = "stack"
+
+// defaultKeys follows the JSON output of hclog, which writes the level, the message and the name itself
+var defaultKeys = logger.Keys{
+	Time:    timeKey,
+	Level:   levelKey,
+	Message: msgKey,
+	File:    callerKey,
+	Func:    funcKey,
+}
@@ -0,0 +1,17 @@
+package hclog
+
+import (
+	"github.com/hashicorp/go-hclog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// hclog has no panic and fatal levels, they are mapped to hclog.Error.
+var defaultLevelMap = logger.LevelMap[hclog.Level]{
+	{Min: logger.LevelTrace, Level: hclog.Trace},
+	{Min: logger.LevelDebug, Level: hclog.Debug},
+	{Min: logger.LevelInfo, Level: hclog.Info},
+	{Min: logger.LevelWarn, Level: hclog.Warn},
+	{Min: logger.LevelError, Level: hclog.Error},
+}
@@ -0,0 +1,131 @@
+package helper
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Flatten appends normalized attrs to dst with their groups inlined,
+// the keys are prefixed by prefix and by the keys of their groups, each followed by ".".
+func Flatten(dst []slog.Attr, prefix string, attrs []slog.Attr) []slog.Attr {
+	for _, attr := range attrs {
+		if attr.Value.Kind() == slog.KindGroup {
+			dst = Flatten(dst, prefix+attr.Key+".", attr.Value.Group())
+			continue
+		}
+		dst = append(dst, slog.Attr{Key: prefix + attr.Key, Value: attr.Value})
+	}
+	return dst
+}
+
+// NestedValue returns the value of a normalized attr, a group becomes a map[string]any of its attrs,
+// for the backends which encode nested maps as JSON objects.
+func NestedValue(v slog.Value) any {
+	if v.Kind() != slog.KindGroup {
+		return v.Any()
+	}
+	m := make(map[string]any, len(v.Group()))
+	for _, attr := range v.Group() {
+		m[attr.Key] = NestedValue(attr.Value)
+	}
+	return m
+}
+
+// WriteLogfmt writes normalized attrs as logfmt, each preceded by a space, with their groups flattened by Flatten.
+// The values are formatted as slog.TextHandler does, the keys and the values are quoted
+// when they are empty or have spaces, '=', '"' or unprintable characters.
+func WriteLogfmt(buf *bytes.Buffer, attrs []slog.Attr) {
+	for _, attr := range Flatten(nil, "", attrs) {
+		buf.WriteByte(' ')
+		writeLogfmtString(buf, attr.Key)
+		buf.WriteByte('=')
+		writeLogfmtString(buf, textValue(attr.Value))
+	}
+}
+
+// textValue formats a value as slog.TextHandler does
+func textValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case encoding.TextMarshaler:
+			data, err := x.MarshalText()
+			if err != nil {
+				return "!ERROR:" + err.Error()
+			}
+			return string(data)
+		case []byte:
+			return string(x)
+		default:
+			return fmt.Sprintf("%+v", x)
+		}
+	default:
+		return v.String()
+	}
+}
+
+func writeLogfmtString(buf *bytes.Buffer, s string) {
+	if needsQuoting(s) {
+		buf.WriteString(strconv.Quote(s))
+	} else {
+		buf.WriteString(s)
+	}
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes normalized attrs as the members of a JSON object, in order, each preceded by a comma
+// unless first is true for the first member, groups become nested objects.
+// An error which is not a json.Marshaler is written as its message, as slog.JSONHandler does,
+// and a value which json.Marshal fails on is written as "!ERROR:" followed by the error.
+func WriteJSON(buf *bytes.Buffer, attrs []slog.Attr, first bool) {
+	for _, attr := range attrs {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeJSONValue(buf, attr.Key)
+		buf.WriteByte(':')
+		if attr.Value.Kind() == slog.KindGroup {
+			buf.WriteByte('{')
+			WriteJSON(buf, attr.Value.Group(), true)
+			buf.WriteByte('}')
+			continue
+		}
+		if err, ok := attr.Value.Any().(error); ok {
+			if _, ok := err.(json.Marshaler); !ok {
+				writeJSONValue(buf, err.Error())
+				continue
+			}
+		}
+		writeJSONValue(buf, attr.Value.Any())
+	}
+}
+
+// writeJSONValue writes the JSON of v, or the error of json.Marshal as a string
+func writeJSONValue(buf *bytes.Buffer, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal("!ERROR:" + err.Error())
+	}
+	buf.Write(data)
+}
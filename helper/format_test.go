@@ -0,0 +1,52 @@
+package helper
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var formatAttrs = []slog.Attr{
+	slog.Int("a", 1),
+	slog.Group("g", slog.String("b", "two"), slog.Group("h", slog.Any("err", io.EOF))),
+	slog.Duration("d", time.Second),
+}
+
+func TestFlatten(t *testing.T) {
+	assert.Equal(t, []slog.Attr{
+		slog.Int("s.a", 1),
+		slog.String("s.g.b", "two"),
+		slog.Any("s.g.h.err", io.EOF),
+		slog.Duration("s.d", time.Second),
+	}, Flatten(nil, "s.", formatAttrs))
+}
+
+func TestNestedValue(t *testing.T) {
+	assert.Equal(t, map[string]any{"b": "two", "h": map[string]any{"err": io.EOF}}, NestedValue(formatAttrs[1].Value))
+	assert.Equal(t, int64(1), NestedValue(formatAttrs[0].Value))
+}
+
+func TestWriteLogfmt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	WriteLogfmt(buf, append(formatAttrs[:len(formatAttrs):len(formatAttrs)],
+		slog.Time("t", time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)),
+		slog.Any("list", []int{1, 2}),
+		slog.Any("bytes", []byte("raw")),
+		slog.String("quoted", `a "b"`),
+		slog.String("empty", ""),
+		slog.String("a key", "v"),
+	))
+	assert.Equal(t, ` a=1 g.b=two g.h.err=EOF d=1s t=2023-10-16T12:00:00Z list="[1 2]" bytes=raw quoted="a \"b\"" empty="" "a key"=v`, buf.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"msg":"m"`)
+	WriteJSON(buf, append(formatAttrs[:len(formatAttrs):len(formatAttrs)], slog.Any("ch", make(chan int))), false)
+	buf.WriteByte('}')
+	assert.Equal(t, `{"msg":"m","a":1,"g":{"b":"two","h":{"err":"EOF"}},"d":1000000000,"ch":"!ERROR:json: unsupported type: chan int"}`, buf.String())
+}
@@ -37,36 +37,46 @@ func (g *AttrGroup) WithAttrs(attrs []slog.Attr) *AttrGroup {
 
 	return &AttrGroup{
 		name:  g.name,
-		attrs: append(g.attrs, attrs...),
+		attrs: append(g.attrs[:len(g.attrs):len(g.attrs)], attrs...), // the clip keeps siblings from sharing the array
 		top:   g.top,
 	}
 }
 
+// Attrs returns the attrs of g, with a group for each name,
+// the values are resolved so that a LogValuer resolving to a group is kept as a group.
 func (g *AttrGroup) Attrs() []slog.Attr {
 	rt := []slog.Attr{}
 	for head := g; head != nil; head = head.top {
 		if head.name != "" {
 			attrs := make([]any, 0, len(head.attrs)+len(rt))
 
-			for i, attr := range head.attrs {
-				if attr.Key == "" {
+			for _, attr := range head.attrs {
+				attr.Value = attr.Value.Resolve()
+				if isEmpty(attr) {
 					continue
 				}
-				attrs = append(attrs, head.attrs[i])
+				attrs = append(attrs, attr)
 			}
 
-			for i, attr := range rt {
-				if attr.Key == "" {
+			for _, attr := range rt {
+				attr.Value = attr.Value.Resolve()
+				if isEmpty(attr) {
 					continue
 				}
-				attrs = append(attrs, rt[i])
+				attrs = append(attrs, attr)
 			}
 
 			group := slog.Group(head.name, attrs...)
 			rt = []slog.Attr{group}
 		} else {
-			rt = append(rt, head.attrs...)
+			rt = append(head.attrs[:len(head.attrs):len(head.attrs)], rt...)
 		}
 	}
 	return rt
 }
+
+// isEmpty reports whether the attr is dropped by the handlers,
+// a group with an empty key is not empty, its attrs are inlined
+func isEmpty(attr slog.Attr) bool {
+	return attr.Key == "" && attr.Value.Kind() != slog.KindGroup
+}
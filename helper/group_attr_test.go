@@ -64,4 +64,13 @@ func TestAttrs(t *testing.T) {
 		origin := (&AttrGroup{}).WithGroup(groupName000).WithAttrs(attrSetInt001002003)
 		assert.EqualValues(t, origin.Attrs(), []slog.Attr{slog.Group(groupName000, int001, int002, int003)})
 	})
+	t.Run("withattrs logvaluer and attrs", func(t *testing.T) {
+		origin := (&AttrGroup{}).WithGroup(groupName000).
+			WithAttrs([]slog.Attr{slog.Any("", groupValuer{int001}), slog.Any("k", valuer("v"))})
+		assert.EqualValues(t, origin.Attrs(), []slog.Attr{slog.Group(groupName000, slog.Group("", int001), slog.String("k", "v"))})
+	})
 }
+
+type groupValuer []slog.Attr
+
+func (g groupValuer) LogValue() slog.Value { return slog.GroupValue(g...) }
@@ -0,0 +1,128 @@
+package helper
+
+import (
+	"fmt"
+	"log/slog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// maxGroupDepth limits the nesting of groups, which may be unbounded when a LogValuer resolves to a group containing itself
+const maxGroupDepth = 100
+
+// keepDuplicates is a policy for normalize which leaves the repeated keys to a later Normalize
+const keepDuplicates logger.DuplicateKeyPolicy = -1
+
+// Normalize applies the rules of slog.Handler to attrs, so that the backends only have to convert the result:
+// values are resolved and passed to replace (see ReplaceAttr), groups with an empty key are inlined,
+// attrs with an empty key and groups without attrs are dropped,
+// and repeated keys are handled by policy within each group.
+//
+// Values are resolved by slog.Value.Resolve, which limits the number of LogValue calls and recovers from their panics,
+// groups nested deeper than 100 levels are replaced by an error.
+func Normalize(attrs []slog.Attr, replace func(groups []string, a slog.Attr) slog.Attr, policy logger.DuplicateKeyPolicy) ([]slog.Attr, error) {
+	return normalize(attrs, nil, replace, policy)
+}
+
+func normalize(attrs []slog.Attr, groups []string, replace func([]string, slog.Attr) slog.Attr, policy logger.DuplicateKeyPolicy) ([]slog.Attr, error) {
+	out := make([]slog.Attr, 0, len(attrs))
+	var rec func(attrs []slog.Attr) error
+	rec = func(attrs []slog.Attr) error {
+		for _, attr := range attrs {
+			attr = ReplaceAttr(replace, groups, attr)
+
+			if attr.Value.Kind() != slog.KindGroup {
+				if attr.Key != "" {
+					out = append(out, attr)
+				}
+				continue
+			}
+
+			// the attrs of a group with an empty key are inlined
+			if attr.Key == "" {
+				if err := rec(attr.Value.Group()); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if len(groups) >= maxGroupDepth {
+				out = append(out, slog.Any(attr.Key, fmt.Errorf("group nested deeper than %d levels", maxGroupDepth)))
+				continue
+			}
+
+			inner, err := normalize(attr.Value.Group(), append(groups[:len(groups):len(groups)], attr.Key), replace, policy)
+			if err != nil {
+				return err
+			}
+			// a group without attrs is ignored
+			if len(inner) > 0 {
+				out = append(out, slog.Attr{Key: attr.Key, Value: slog.GroupValue(inner...)})
+			}
+		}
+		return nil
+	}
+	if err := rec(attrs); err != nil {
+		return nil, err
+	}
+	return dedup(out, policy)
+}
+
+// dedup handles the repeated keys of attrs, which are already normalized
+func dedup(attrs []slog.Attr, policy logger.DuplicateKeyPolicy) ([]slog.Attr, error) {
+	if policy == keepDuplicates {
+		return attrs, nil
+	}
+	out := make([]slog.Attr, 0, len(attrs))
+	index := map[string]int{}
+	counts := map[string]int{}
+	collected := map[int][]any{}
+	for _, attr := range attrs {
+		i, ok := index[attr.Key]
+		if !ok {
+			index[attr.Key] = len(out)
+			out = append(out, attr)
+			continue
+		}
+
+		prev := out[i]
+		if prev.Value.Kind() == slog.KindGroup && attr.Value.Kind() == slog.KindGroup {
+			merged := append(append([]slog.Attr{}, prev.Value.Group()...), attr.Value.Group()...)
+			inner, err := dedup(merged, policy)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = slog.Attr{Key: attr.Key, Value: slog.GroupValue(inner...)}
+			continue
+		}
+
+		switch policy {
+		case logger.DuplicateKeyFirstWins:
+		case logger.DuplicateKeySuffix:
+			// the suffixed key may already be used, by an attr of the record or by another suffix
+			key := attr.Key
+			for {
+				counts[key]++
+				attr.Key = fmt.Sprintf("%s#%d", key, counts[key]+1)
+				if _, used := index[attr.Key]; !used {
+					break
+				}
+			}
+			index[attr.Key] = len(out)
+			out = append(out, attr)
+		case logger.DuplicateKeyCollect:
+			values, ok := collected[i]
+			if !ok {
+				values = []any{NestedValue(prev.Value)}
+			}
+			values = append(values, NestedValue(attr.Value))
+			collected[i] = values
+			out[i] = slog.Any(attr.Key, values)
+		case logger.DuplicateKeyError:
+			return nil, fmt.Errorf("dup key: %s", attr.Key)
+		default:
+			out[i] = attr
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,138 @@
+package helper
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Run("applies the rules of slog.Handler", func(t *testing.T) {
+		attrs, err := Normalize([]slog.Attr{
+			slog.Any("k", valuer("v")),
+			{},
+			slog.Group("", slog.Int("a", 1)),
+			slog.Group("g", slog.Group("h")),
+			slog.Group("s", slog.Attr{}, slog.Int("b", 2)),
+		}, nil, logger.DuplicateKeyLastWins)
+		assert.NoError(t, err)
+		assert.Equal(t, []slog.Attr{
+			slog.String("k", "v"),
+			slog.Int("a", 1),
+			slog.Group("s", slog.Int("b", 2)),
+		}, attrs)
+	})
+
+	t.Run("replace gets the group path", func(t *testing.T) {
+		attrs, err := Normalize([]slog.Attr{slog.Group("g", slog.Group("", slog.Int("a", 1)))}, func(groups []string, a slog.Attr) slog.Attr {
+			assert.Equal(t, []string{"g"}, groups)
+			return slog.Int("b", 2)
+		}, logger.DuplicateKeyLastWins)
+		assert.NoError(t, err)
+		assert.Equal(t, []slog.Attr{slog.Group("g", slog.Int("b", 2))}, attrs)
+	})
+
+	dups := []slog.Attr{
+		slog.Int("a", 1),
+		slog.Group("g", slog.Int("x", 1)),
+		slog.Int("b", 0),
+		slog.Int("a", 2),
+		slog.Group("g", slog.Int("x", 2), slog.Int("y", 3)),
+		slog.Int("a", 3),
+	}
+	for _, test := range []struct {
+		name   string
+		policy logger.DuplicateKeyPolicy
+		want   []slog.Attr
+	}{
+		{
+			name:   "last wins",
+			policy: logger.DuplicateKeyLastWins,
+			want:   []slog.Attr{slog.Int("a", 3), slog.Group("g", slog.Int("x", 2), slog.Int("y", 3)), slog.Int("b", 0)},
+		},
+		{
+			name:   "first wins",
+			policy: logger.DuplicateKeyFirstWins,
+			want:   []slog.Attr{slog.Int("a", 1), slog.Group("g", slog.Int("x", 1), slog.Int("y", 3)), slog.Int("b", 0)},
+		},
+		{
+			name:   "suffix",
+			policy: logger.DuplicateKeySuffix,
+			want: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g", slog.Int("x", 1), slog.Int("x#2", 2), slog.Int("y", 3)),
+				slog.Int("b", 0),
+				slog.Int("a#2", 2),
+				slog.Int("a#3", 3),
+			},
+		},
+		{
+			name:   "collect",
+			policy: logger.DuplicateKeyCollect,
+			want: []slog.Attr{
+				slog.Any("a", []any{int64(1), int64(2), int64(3)}),
+				slog.Group("g", slog.Any("x", []any{int64(1), int64(2)}), slog.Int("y", 3)),
+				slog.Int("b", 0),
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			attrs, err := Normalize(dups, nil, test.policy)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, attrs)
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		_, err := Normalize(dups, nil, logger.DuplicateKeyError)
+		assert.EqualError(t, err, "dup key: a")
+	})
+
+	t.Run("suffix skips the keys in use", func(t *testing.T) {
+		attrs, err := Normalize([]slog.Attr{slog.Int("a", 1), slog.Int("a#2", 2), slog.Int("a", 3), slog.Int("a", 4)}, nil, logger.DuplicateKeySuffix)
+		assert.NoError(t, err)
+		assert.Equal(t, []slog.Attr{slog.Int("a", 1), slog.Int("a#2", 2), slog.Int("a#3", 3), slog.Int("a#4", 4)}, attrs)
+	})
+
+	t.Run("collect a group and a value", func(t *testing.T) {
+		attrs, err := Normalize([]slog.Attr{slog.Int("a", 1), slog.Group("a", slog.Int("b", 2))}, nil, logger.DuplicateKeyCollect)
+		assert.NoError(t, err)
+		assert.Equal(t, []slog.Attr{slog.Any("a", []any{int64(1), map[string]any{"b": int64(2)}})}, attrs)
+	})
+	t.Run("resolves nested LogValuers", func(t *testing.T) {
+		attrs, err := Normalize([]slog.Attr{
+			slog.Any("g", groupValuer{slog.Any("k", valuer("v")), slog.Any("h", groupValuer{int001})}),
+		}, nil, logger.DuplicateKeyLastWins)
+		assert.NoError(t, err)
+		assert.Equal(t, []slog.Attr{slog.Group("g", slog.String("k", "v"), slog.Group("h", int001))}, attrs)
+	})
+
+	t.Run("limits the depth", func(t *testing.T) {
+		attrs, err := Normalize([]slog.Attr{slog.Any("loop", loopValuer{})}, nil, logger.DuplicateKeyLastWins)
+		assert.NoError(t, err)
+		for depth := 0; depth < maxGroupDepth; depth++ {
+			assert.Len(t, attrs, 1)
+			assert.Equal(t, slog.KindGroup, attrs[0].Value.Kind())
+			attrs = attrs[0].Value.Group()
+		}
+		assert.EqualError(t, attrs[0].Value.Any().(error), "group nested deeper than 100 levels")
+	})
+
+	t.Run("recovers a panicking LogValuer", func(t *testing.T) {
+		attrs, err := Normalize([]slog.Attr{slog.Any("p", panicValuer{})}, nil, logger.DuplicateKeyLastWins)
+		assert.NoError(t, err)
+		assert.Contains(t, attrs[0].Value.Any().(error).Error(), "LogValue panicked")
+	})
+}
+
+// loopValuer resolves to a group containing itself
+type loopValuer struct{}
+
+func (loopValuer) LogValue() slog.Value { return slog.GroupValue(slog.Any("loop", loopValuer{})) }
+
+type panicValuer struct{}
+
+func (panicValuer) LogValue() slog.Value { panic("boom") }
@@ -0,0 +1,72 @@
+package helper
+
+import (
+	"log/slog"
+	"runtime"
+)
+
+// ReplaceAttr resolves the value of attr and passes it to replace, following slog.HandlerOptions.ReplaceAttr:
+// replace is not called for groups, and the value it returns is resolved again.
+// A nil replace returns the resolved attr.
+func ReplaceAttr(replace func(groups []string, a slog.Attr) slog.Attr, groups []string, attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if replace == nil || attr.Value.Kind() == slog.KindGroup {
+		return attr
+	}
+	attr = replace(groups, attr)
+	attr.Value = attr.Value.Resolve()
+	return attr
+}
+
+// Builtins are the built-in attributes of a record after ReplaceAttr.
+//
+// The backends write the level and the message themselves, so ReplaceAttr can only change their values:
+// a slog.Level returned for slog.LevelKey selects the level, the value returned for slog.MessageKey
+// becomes the message, and an empty key empties the message.
+//
+// Time and Source are empty when the record has none or ReplaceAttr dropped them,
+// otherwise they keep the keys slog.TimeKey and slog.SourceKey unless ReplaceAttr renamed them.
+type Builtins struct {
+	Level   slog.Level
+	Message string
+	Time    slog.Attr
+	Source  slog.Attr
+}
+
+// ReplaceBuiltins passes the built-in attributes of r to replace, with nil groups.
+// The time is omitted if zero, and the source is omitted if addSource is false or r.PC is zero,
+// the value of the source is a *slog.Source.
+func ReplaceBuiltins(replace func(groups []string, a slog.Attr) slog.Attr, r slog.Record, addSource bool) Builtins {
+	b := Builtins{Level: r.Level, Message: r.Message}
+
+	if !r.Time.IsZero() {
+		b.Time = ReplaceAttr(replace, nil, slog.Time(slog.TimeKey, r.Time))
+	}
+
+	if replace != nil {
+		a := ReplaceAttr(replace, nil, slog.Any(slog.LevelKey, r.Level))
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			b.Level = level
+		}
+	}
+
+	if addSource && r.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		b.Source = ReplaceAttr(replace, nil, slog.Any(slog.SourceKey, &slog.Source{
+			Function: f.Function,
+			File:     f.File,
+			Line:     f.Line,
+		}))
+	}
+
+	if replace != nil {
+		if a := ReplaceAttr(replace, nil, slog.String(slog.MessageKey, r.Message)); a.Key != "" {
+			b.Message = a.Value.String()
+		} else {
+			b.Message = ""
+		}
+	}
+
+	return b
+}
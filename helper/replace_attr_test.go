@@ -0,0 +1,96 @@
+package helper
+
+import (
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type valuer string
+
+func (v valuer) LogValue() slog.Value { return slog.StringValue(string(v)) }
+
+func TestReplaceAttr(t *testing.T) {
+	t.Run("nil replace resolves the value", func(t *testing.T) {
+		attr := ReplaceAttr(nil, nil, slog.Any("k", valuer("v")))
+		assert.Equal(t, slog.String("k", "v"), attr)
+	})
+
+	t.Run("replace is called with the resolved value and the groups", func(t *testing.T) {
+		var gotGroups []string
+		attr := ReplaceAttr(func(groups []string, a slog.Attr) slog.Attr {
+			gotGroups = groups
+			assert.Equal(t, slog.KindString, a.Value.Kind())
+			return slog.Any("renamed", valuer(a.Value.String()+"!"))
+		}, []string{"g", "h"}, slog.Any("k", valuer("v")))
+		assert.Equal(t, []string{"g", "h"}, gotGroups)
+		assert.Equal(t, slog.String("renamed", "v!"), attr)
+	})
+
+	t.Run("replace is not called for groups", func(t *testing.T) {
+		group := slog.Group("g", int001)
+		attr := ReplaceAttr(func(groups []string, a slog.Attr) slog.Attr {
+			t.Fatal("unexpected call")
+			return a
+		}, nil, group)
+		assert.Equal(t, group, attr)
+	})
+}
+
+func TestReplaceBuiltins(t *testing.T) {
+	now := time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	t.Run("without replace", func(t *testing.T) {
+		b := ReplaceBuiltins(nil, slog.NewRecord(now, slog.LevelWarn, "message", 0), true)
+		assert.Equal(t, Builtins{
+			Level:   slog.LevelWarn,
+			Message: "message",
+			Time:    slog.Time(slog.TimeKey, now),
+		}, b)
+	})
+
+	t.Run("with replace", func(t *testing.T) {
+		var keys []string
+		b := ReplaceBuiltins(func(groups []string, a slog.Attr) slog.Attr {
+			assert.Nil(t, groups)
+			keys = append(keys, a.Key)
+			switch a.Key {
+			case slog.TimeKey:
+				return slog.Int64("ts", a.Value.Time().Unix())
+			case slog.LevelKey:
+				return slog.Any(a.Key, slog.LevelError)
+			case slog.MessageKey:
+				return slog.String(a.Key, "replaced")
+			}
+			return a
+		}, slog.NewRecord(now, slog.LevelWarn, "message", 0), false)
+		assert.Equal(t, []string{slog.TimeKey, slog.LevelKey, slog.MessageKey}, keys)
+		assert.Equal(t, Builtins{
+			Level:   slog.LevelError,
+			Message: "replaced",
+			Time:    slog.Int64("ts", now.Unix()),
+		}, b)
+	})
+
+	t.Run("source", func(t *testing.T) {
+		var pcs [1]uintptr
+		runtime.Callers(1, pcs[:])
+		b := ReplaceBuiltins(nil, slog.NewRecord(now, slog.LevelInfo, "message", pcs[0]), true)
+		assert.Equal(t, slog.SourceKey, b.Source.Key)
+		src, ok := b.Source.Value.Any().(*slog.Source)
+		if assert.True(t, ok) {
+			assert.Equal(t, "github.com/m40Jc001/slog-handler-adapter/helper.TestReplaceBuiltins.func3", src.Function)
+		}
+	})
+
+	t.Run("zero time is omitted", func(t *testing.T) {
+		b := ReplaceBuiltins(func(groups []string, a slog.Attr) slog.Attr {
+			assert.NotEqual(t, slog.TimeKey, a.Key)
+			return a
+		}, slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0), false)
+		assert.Equal(t, slog.Attr{}, b.Time)
+	})
+}
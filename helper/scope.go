@@ -0,0 +1,95 @@
+package helper
+
+import (
+	"log/slog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// Scope tracks the groups and attrs added to a handler by WithGroup and WithAttrs,
+// so that a backend can convert the attrs once when they are added, instead of on every record.
+//
+// The attrs are normalized when they are added, with the group path of the scope,
+// and the backend places them in the innermost group, as it does with the attrs of a record.
+// This gives the same output as normalizing all the attrs of a record at once, unless a key repeats
+// in the same group across the calls, Conflict then reports true and the backend should use Attrs.
+type Scope struct {
+	attrGroup *AttrGroup
+	groups    []string
+	keys      map[string]struct{} // the keys added to the innermost group
+	conflict  bool
+}
+
+// NewScope returns a scope without groups and attrs.
+func NewScope() *Scope {
+	return &Scope{attrGroup: &AttrGroup{}}
+}
+
+// Groups returns the names of the open groups, the outermost first.
+func (s *Scope) Groups() []string {
+	return s.groups
+}
+
+// WithGroup returns a scope with the group name opened, if name is empty it returns s.
+func (s *Scope) WithGroup(name string) *Scope {
+	if name == "" {
+		return s
+	}
+	_, conflict := s.keys[name]
+	return &Scope{
+		attrGroup: s.attrGroup.WithGroup(name),
+		groups:    append(s.groups[:len(s.groups):len(s.groups)], name),
+		conflict:  s.conflict || conflict,
+	}
+}
+
+// WithAttrs returns a scope with attrs added to the innermost group,
+// and the normalized attrs, which the backend has to convert, their repeated keys are left as is.
+func (s *Scope) WithAttrs(attrs []slog.Attr, replace func(groups []string, a slog.Attr) slog.Attr) (*Scope, []slog.Attr) {
+	normalized, _ := normalize(attrs, s.groups, replace, keepDuplicates)
+	if len(normalized) == 0 {
+		return s, nil
+	}
+
+	cp := &Scope{
+		attrGroup: s.attrGroup.WithAttrs(normalized),
+		groups:    s.groups,
+		keys:      make(map[string]struct{}, len(s.keys)+len(normalized)),
+		conflict:  s.conflict,
+	}
+	for key := range s.keys {
+		cp.keys[key] = struct{}{}
+	}
+	for _, attr := range normalized {
+		if _, ok := cp.keys[attr.Key]; ok {
+			cp.conflict = true
+		}
+		cp.keys[attr.Key] = struct{}{}
+	}
+	return cp, normalized
+}
+
+// Normalize normalizes the attrs of a record in the innermost group, see Normalize.
+func (s *Scope) Normalize(attrs []slog.Attr, replace func(groups []string, a slog.Attr) slog.Attr, policy logger.DuplicateKeyPolicy) ([]slog.Attr, error) {
+	return normalize(attrs, s.groups, replace, policy)
+}
+
+// Conflict reports whether a key repeats across the calls of WithAttrs and WithGroup,
+// or between them and the normalized attrs of a record.
+func (s *Scope) Conflict(attrs []slog.Attr) bool {
+	if s.conflict {
+		return true
+	}
+	for _, attr := range attrs {
+		if _, ok := s.keys[attr.Key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Attrs returns the attrs of the scope and the normalized attrs of a record, in their groups,
+// with the repeated keys handled by policy.
+func (s *Scope) Attrs(attrs []slog.Attr, policy logger.DuplicateKeyPolicy) ([]slog.Attr, error) {
+	return Normalize(s.attrGroup.WithAttrs(attrs).Attrs(), nil, policy)
+}
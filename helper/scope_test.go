@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+func TestScope(t *testing.T) {
+	t.Run("with attrs normalizes in the group path", func(t *testing.T) {
+		var gotGroups []string
+		scope, attrs := NewScope().WithGroup(groupName000).WithAttrs([]slog.Attr{{}, slog.Any("k", valuer("v"))}, func(groups []string, a slog.Attr) slog.Attr {
+			gotGroups = groups
+			return a
+		})
+		assert.Equal(t, []string{groupName000}, gotGroups)
+		assert.Equal(t, []string{groupName000}, scope.Groups())
+		assert.Equal(t, []slog.Attr{slog.String("k", "v")}, attrs)
+		assert.False(t, scope.Conflict([]slog.Attr{int001}))
+		assert.True(t, scope.Conflict([]slog.Attr{slog.Int("k", 1)}))
+	})
+
+	t.Run("empty attrs and name return the scope", func(t *testing.T) {
+		origin := NewScope()
+		scope, attrs := origin.WithAttrs([]slog.Attr{{}, slog.Group("g")}, nil)
+		assert.Same(t, origin, scope)
+		assert.Empty(t, attrs)
+		assert.Same(t, origin, origin.WithGroup(""))
+	})
+
+	t.Run("keys repeated across calls conflict", func(t *testing.T) {
+		scope, _ := NewScope().WithAttrs([]slog.Attr{int001}, nil)
+		assert.True(t, scope.WithGroup(int001.Key).Conflict(nil))
+
+		scope, _ = scope.WithAttrs([]slog.Attr{int001}, nil)
+		assert.True(t, scope.Conflict(nil))
+	})
+
+	t.Run("the keys of outer groups do not conflict", func(t *testing.T) {
+		scope, _ := NewScope().WithAttrs([]slog.Attr{int001}, nil)
+		assert.False(t, scope.WithGroup(groupName000).Conflict([]slog.Attr{int001}))
+	})
+
+	t.Run("attrs applies the policy to all the attrs", func(t *testing.T) {
+		scope, _ := NewScope().WithAttrs([]slog.Attr{int001}, nil)
+		scope, _ = scope.WithGroup(groupName000).WithAttrs([]slog.Attr{int002}, nil)
+		attrs, err := scope.Attrs([]slog.Attr{slog.Int("int002", 4), int003}, logger.DuplicateKeyFirstWins)
+		assert.NoError(t, err)
+		assert.Equal(t, []slog.Attr{int001, slog.Group(groupName000, int002, int003)}, attrs)
+	})
+}
@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Stack returns the stack trace of the current goroutine in the format of zap,
+// the function of each frame followed by a tab and file:line on the next line.
+//
+// The trace starts at the frame of pc, the PC of a record, so that the frames of log/slog and of the handler are left out.
+// If pc is not on the stack, such as when the record is handled on another goroutine,
+// the trace starts at the caller of Stack, after skipping skip frames.
+//
+// The handlers add it under the "stack" key to the records at or above their StacktraceLevel option.
+func Stack(pc uintptr, skip int) string {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2+skip, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+
+	for i, p := range pcs {
+		if p == pc {
+			pcs = pcs[i:]
+			break
+		}
+	}
+
+	sb := &strings.Builder{}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(sb, "%s\n\t%s:%d", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,33 @@
+package helper
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStack(t *testing.T) {
+	// the trace starts at pc
+	stack := logWithPC()
+	assert.True(t, strings.HasPrefix(stack, "github.com/m40Jc001/slog-handler-adapter/helper.TestStack\n\t"), stack)
+	assert.Contains(t, stack, "helper/stack_test.go:13\n")
+
+	// without pc it starts at the caller of the handler
+	stack = stackFromHandler(0)
+	assert.True(t, strings.HasPrefix(stack, "github.com/m40Jc001/slog-handler-adapter/helper.TestStack\n\t"), stack)
+	assert.Contains(t, stack, "helper/stack_test.go:18\n")
+}
+
+// logWithPC takes the PC of its caller, as slog.Logger does, and handles it
+func logWithPC() string {
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	return stackFromHandler(pcs[0])
+}
+
+// stackFromHandler calls Stack as a handler does, skipping its own frame
+func stackFromHandler(pc uintptr) string {
+	return Stack(pc, 1)
+}
@@ -0,0 +1,38 @@
+package logger
+
+// Keys are the output keys of the built-in attributes of a record,
+// an empty key is replaced by the default of the backend.
+type Keys struct {
+	Time    string
+	Level   string
+	Message string
+	File    string
+	Func    string
+
+	// Source, when not empty, puts the source of a record into a single object under this key,
+	// with the fields "function", "file" and "line" of slog.Source, instead of the File and Func keys.
+	Source string
+}
+
+// WithDefaults returns k with its empty keys taken from defaults.
+func (k Keys) WithDefaults(defaults Keys) Keys {
+	if k.Time == "" {
+		k.Time = defaults.Time
+	}
+	if k.Level == "" {
+		k.Level = defaults.Level
+	}
+	if k.Message == "" {
+		k.Message = defaults.Message
+	}
+	if k.File == "" {
+		k.File = defaults.File
+	}
+	if k.Func == "" {
+		k.Func = defaults.Func
+	}
+	if k.Source == "" {
+		k.Source = defaults.Source
+	}
+	return k
+}
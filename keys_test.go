@@ -0,0 +1,17 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeysWithDefaults(t *testing.T) {
+	defaults := Keys{Time: "time", Level: "level", Message: "msg", File: "file", Func: "func"}
+
+	assert.Equal(t, defaults, Keys{}.WithDefaults(defaults))
+	assert.Equal(t,
+		Keys{Time: "@timestamp", Level: "severity", Message: "msg", File: "file", Func: "func", Source: "caller"},
+		Keys{Time: "@timestamp", Level: "severity", Source: "caller"}.WithDefaults(defaults),
+	)
+}
@@ -0,0 +1,323 @@
+package klog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/helper"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
+
+const stackKey string = "stack"
+
+type Handler struct {
+	mu        *sync.Mutex
+	out       io.Writer
+	pid       int
+	level     *slog.LevelVar
+	attrGroup *helper.AttrGroup
+	levelMap  logger.LevelMap[Severity]
+	replace   func(groups []string, a slog.Attr) slog.Attr
+	dupPolicy logger.DuplicateKeyPolicy
+	terminate logger.TerminateFunc
+	ctxAttrs  []logger.ContextExtractor
+	stack     slog.Leveler
+	quoteMsg  bool
+}
+
+type HandlerOptions struct {
+	Level slog.Level
+
+	// QuoteMessage quotes the message as klog.InfoS does,
+	// by default the message is written as is, as klog.Info does.
+	QuoteMessage bool
+
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps slog levels to the severity of the header,
+	// by default the levels below logger.LevelWarn are InfoSeverity, see V,
+	// and logger.LevelPanic is ErrorSeverity.
+	LevelMap logger.LevelMap[Severity]
+
+	// ReplaceAttr follows helper.Builtins,
+	// a time or a source which is removed or replaced by another kind of value is left out of the header.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+	Terminate          logger.TerminateFunc
+	ExitFunc           func(code int)
+}
+
+// NewHandler writes the lines of klog to writer, each with a single Write call under a lock:
+//
+//	Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg key=value
+//
+// L is the severity, the time is written in the location of the record time,
+// the thread id is the process id padded to 7 columns and file is the base name of the source file,
+// as klog and glog write them.
+// The message is written as klog.Info writes it, or quoted as klog.InfoS does with QuoteMessage.
+// The attrs follow the structured format of klog.InfoS:
+// the string values are quoted, other values are written with %+v,
+// and a string with line breaks is written as key=< followed by its lines indented with a tab and " >".
+// The keys in groups are prefixed by the group keys.
+//
+// The source always comes from Record.PC, a record without PC is written with "???:1", as klog does.
+func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
+	levelar := &slog.LevelVar{}
+	levelar.Set(options.Level)
+
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
+	return &Handler{
+		mu:        &sync.Mutex{},
+		out:       writer,
+		pid:       os.Getpid(),
+		level:     levelar,
+		attrGroup: &helper.AttrGroup{},
+		levelMap:  levelMap,
+		replace:   options.ReplaceAttr,
+		dupPolicy: options.DuplicateKeyPolicy,
+		terminate: terminate,
+		ctxAttrs:  options.ContextExtractors,
+		stack:     options.StacktraceLevel,
+		quoteMsg:  options.QuoteMessage,
+	}
+}
+
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		mu:        h.mu,
+		out:       h.out,
+		pid:       h.pid,
+		level:     h.level,
+		attrGroup: h.attrGroup,
+		levelMap:  h.levelMap,
+		replace:   h.replace,
+		dupPolicy: h.dupPolicy,
+		terminate: h.terminate,
+		ctxAttrs:  h.ctxAttrs,
+		stack:     h.stack,
+		quoteMsg:  h.quoteMsg,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		return level >= override
+	}
+	return level >= h.level.Level()
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle methods that produce output should observe the following rules:
+//   - If r.Time is the zero time, ignore the time.
+//   - If r.PC is zero, ignore it.
+//   - Attr's values should be resolved.
+//   - If an Attr's key and value are both the zero value, ignore the Attr.
+//     This can be tested with attr.Equal(Attr{}).
+//   - If a group's key is empty, inline the group's Attrs.
+//   - If a group has no Attrs (even if it has a non-empty key),
+//     ignore it.
+//
+// The header of klog always has a time, a record with the zero time is written with it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+
+	attrs, err := helper.Normalize(h.attrGroup.WithAttrs(recordAttrs).Attrs(), h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
+
+	b := helper.ReplaceBuiltins(h.replace, r, true)
+
+	var t time.Time
+	if b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime {
+		t = b.Time.Value.Time()
+	} else if b.Time.Key != "" {
+		attrs = append([]slog.Attr{b.Time}, attrs...)
+	}
+
+	file, line := "???", 1
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		file, line = src.File, src.Line
+		if i := strings.LastIndexByte(file, '/'); i >= 0 {
+			file = file[i+1:]
+		}
+	} else if b.Source.Key != "" {
+		attrs = append(attrs, b.Source)
+	}
+
+	if h.stack != nil && b.Level >= h.stack.Level() {
+		attrs = append(attrs, slog.String(stackKey, helper.Stack(r.PC, 1)))
+	}
+
+	buf := &bytes.Buffer{}
+	_, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	fmt.Fprintf(buf, "%c%02d%02d %02d:%02d:%02d.%06d %7d %s:%d] ",
+		h.levelMap.Map(b.Level), int(month), day, hour, minute, second, t.Nanosecond()/1000, h.pid, file, line)
+	if h.quoteMsg {
+		buf.WriteString(strconv.Quote(b.Message))
+	} else {
+		buf.WriteString(b.Message)
+	}
+	writeAttrs(buf, "", attrs)
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	_, err = h.out.Write(buf.Bytes())
+	h.mu.Unlock()
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
+	return err
+}
+
+// writeAttrs writes normalized attrs as key=value pairs, the keys in groups are prefixed by the group keys
+func writeAttrs(buf *bytes.Buffer, prefix string, attrs []slog.Attr) {
+	for _, attr := range helper.Flatten(nil, prefix, attrs) {
+		buf.WriteByte(' ')
+		buf.WriteString(attr.Key)
+		writeValue(buf, attr.Value)
+	}
+}
+
+// writeValue writes the value of an attr, with the "=" or "=<" that separates it from the key, as klog does
+func writeValue(buf *bytes.Buffer, v slog.Value) {
+	switch x := v.Any().(type) {
+	case string:
+		writeString(buf, x)
+	case error:
+		writeString(buf, safeString(x, x.Error))
+	case fmt.Stringer:
+		writeString(buf, safeString(x, x.String))
+	default:
+		buf.WriteByte('=')
+		fmt.Fprintf(buf, "%+v", x)
+	}
+}
+
+// safeString returns the result of f, the Error or String method of v,
+// a panic is written as "<nil>" when v is a nil pointer, as log/slog does, and as "<panic: ...>" otherwise, as klog does
+func safeString(v any, f func() string) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+				s = "<nil>"
+				return
+			}
+			s = fmt.Sprintf("<panic: %v>", r)
+		}
+	}()
+	return f()
+}
+
+// writeString quotes s, or writes its lines indented by a tab between "=<" and " >" when it has line breaks
+func writeString(buf *bytes.Buffer, s string) {
+	if !strings.Contains(s, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(strconv.Quote(s))
+		return
+	}
+	buf.WriteString("=<\n")
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n") {
+		buf.WriteByte('\t')
+		buf.WriteString(line)
+	}
+	buf.WriteString("\n >")
+}
+
+// Sync flushes the writer, see logger.SyncWriter.
+func (h *Handler) Sync() error {
+	return logger.SyncWriter(h.out)
+}
+
+// Close syncs the writer and closes it, see logger.CloseWriter.
+func (h *Handler) Close() error {
+	return errors.Join(h.Sync(), logger.CloseWriter(h.out))
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	return cp
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	return cp
+}
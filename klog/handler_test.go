@@ -0,0 +1,331 @@
+package klog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+/*
+	This unit test is adapted from go 1.21.1 log/slog/handler_test.go
+	with slight modifications to test the klog handler
+*/
+
+// header is the header of a record without time and PC
+var header = fmt.Sprintf("I0101 00:00:00.000000 %7d ???:1] ", os.Getpid())
+
+func TestDefaultHandle(t *testing.T) {
+	ctx := context.Background()
+	preAttrs := []slog.Attr{slog.Int("pre", 0)}
+	attrs := []slog.Attr{slog.Int("a", 1), slog.String("b", "two")}
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "no attrs",
+			want: `message`,
+		},
+		{
+			name:  "attrs",
+			attrs: attrs,
+			want:  `message a=1 b="two"`,
+		},
+		{
+			name:  "preformatted",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs) },
+			attrs: attrs,
+			want:  `message pre=0 a=1 b="two"`,
+		},
+		{
+			name: "groups",
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g",
+					slog.Int("b", 2),
+					slog.Group("h", slog.Int("c", 3)),
+					slog.Int("d", 4)),
+				slog.Int("e", 5),
+			},
+			want: `message a=1 g.b=2 g.h.c=3 g.d=4 e=5`,
+		},
+		{
+			name:  "group",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
+			attrs: attrs,
+			want:  `message pre=0 s.a=1 s.b="two"`,
+		},
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  `message p1=1 s1.p2=2 s1.s2.a=1 s1.s2.b="two"`,
+		},
+		{
+			name: "two with-groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  `message p1=1 s1.s2.a=1 s1.s2.b="two"`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+
+			buf := &bytes.Buffer{}
+
+			var h slog.Handler = NewHandler(buf, &HandlerOptions{})
+
+			if test.with != nil {
+				h = test.with(h)
+			}
+
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			got = buf.String()
+			if want := header + test.want + "\n"; got != want {
+				t.Errorf("\ngot  %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	_, _, line, _ := runtime.Caller(0)
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 123456789, time.UTC), slog.LevelWarn, `say "hi"`, pcs[0])
+	r.AddAttrs(
+		slog.Any("err", errors.New("boom")),
+		slog.Duration("d", time.Second),
+		slog.Bool("ok", true),
+		slog.String("lines", "a\nb\n"),
+	)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprintf("W1016 12:00:00.123456 %7d handler_test.go:%d] say \"hi\" err=\"boom\" d=\"1s\" ok=true lines=<\n\ta\n\tb\n >\n",
+		os.Getpid(), line-1)
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+
+	buf.Reset()
+	h = NewHandler(buf, &HandlerOptions{QuoteMessage: true})
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, `say "hi"`, 0)); err != nil {
+		t.Fatal(err)
+	}
+	want = fmt.Sprintf("I0101 00:00:00.000000 %7d ???:1] \"say \\\"hi\\\"\"\n", os.Getpid())
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+type nilStringer struct {
+	s string
+}
+
+func (n *nilStringer) String() string { return n.s }
+
+type panicError struct{}
+
+func (panicError) Error() string { panic("boom") }
+
+func TestPanickingValues(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{})
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Any("nil", (*nilStringer)(nil)), slog.Any("err", panicError{}))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprintf("I0101 00:00:00.000000 %7d ???:1] message nil=\"<nil>\" err=\"<panic: boom>\"\n", os.Getpid())
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	count := 1000
+	var buf bytes.Buffer
+	h := NewHandler(&buf, &HandlerOptions{})
+	sub1 := h.WithAttrs([]slog.Attr{slog.Bool("sub1", true)})
+	sub2 := h.WithAttrs([]slog.Attr{slog.Bool("sub2", true)})
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		sub1Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub1", 0)
+		sub1Record.AddAttrs(slog.Int("i", i))
+		sub2Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub2", 0)
+		sub2Record.AddAttrs(slog.Int("i", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sub1.Handle(ctx, sub1Record); err != nil {
+				t.Error(err)
+			}
+			if err := sub2.Handle(ctx, sub2Record); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 1; i <= 2; i++ {
+		want := "hello from sub" + strconv.Itoa(i)
+		n := strings.Count(buf.String(), want)
+		if n != count {
+			t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+		}
+	}
+}
+
+var headerRegexp = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}\.\d{6}) +(\d+) ([^ \]]+)\] (.*)$`)
+
+// messageRegexp splits the message from the key=value pairs which follow it
+var messageRegexp = regexp.MustCompile(`^(.*?)((?: [^ ="]+=.*)?)$`)
+
+// parseLine parses the header, the message and the key=value pairs of a line
+func parseLine(line []byte) (map[string]any, error) {
+	match := headerRegexp.FindStringSubmatch(string(line))
+	if match == nil {
+		return nil, errors.New("no klog header")
+	}
+	msg := messageRegexp.FindStringSubmatch(match[5])
+	m, err := conformance.ParseLogfmtLine([]byte(msg[2]))
+	if err != nil {
+		return nil, err
+	}
+	m = conformance.Unflatten(m, ".")
+	m[slog.LevelKey] = match[1]
+	// the header always has a time, the zero time stands for a record without time, see TestZeroTime
+	if match[2] != "0101 00:00:00.000000" {
+		m[slog.TimeKey] = match[2]
+	}
+	m[slog.MessageKey] = msg[1]
+	if match[4] != "???:1" {
+		m[slog.SourceKey] = match[4]
+	}
+	return m, nil
+}
+
+// multilineRegexp matches a string value with line breaks, written between "=<" and " >" with its lines indented by a tab
+var multilineRegexp = regexp.MustCompile(`=<\n((?:\t.*\n)*) >`)
+
+// parseOutput quotes the string values with line breaks, then parses the lines
+func parseOutput(out []byte) ([]map[string]any, error) {
+	out = multilineRegexp.ReplaceAllFunc(out, func(value []byte) []byte {
+		lines := multilineRegexp.FindSubmatch(value)[1]
+		s := strings.TrimSuffix(strings.ReplaceAll("\n"+string(lines), "\n\t", "\n")[1:], "\n")
+		return []byte("=" + strconv.Quote(s))
+	})
+	return conformance.ParseLines(out, parseLine)
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Backend{
+		NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+			return NewHandler(w, &HandlerOptions{})
+		},
+		Parse: parseOutput,
+		NewHandlerWithOptions: func(w io.Writer, options conformance.Options) slog.Handler {
+			return NewHandler(w, &HandlerOptions{
+				Level:              options.Level,
+				StacktraceLevel:    options.StacktraceLevel,
+				ReplaceAttr:        options.ReplaceAttr,
+				DuplicateKeyPolicy: options.DuplicateKeyPolicy,
+				ContextExtractors:  options.ContextExtractors,
+				Terminate:          options.Terminate,
+				ExitFunc:           options.ExitFunc,
+			})
+		},
+		OwnsWriter: true,
+	})
+}
+
+// TestZeroTime shows how a record without time is written, the header of klog always has a time.
+func TestZeroTime(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{})
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("I0101 00:00:00.000000 %7d ???:1] message\n", os.Getpid())
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[Severity]
+		level    slog.Level
+		want     Severity
+	}{
+		{name: "verbosity", level: V(4), want: InfoSeverity},
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: InfoSeverity},
+		{name: "warn", level: slog.LevelWarn, want: WarningSeverity},
+		{name: "panic", level: logger.LevelPanic, want: ErrorSeverity},
+		{name: "fatal", level: logger.LevelFatal, want: FatalSeverity},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[Severity]{{Min: slog.LevelDebug, Level: InfoSeverity}, {Min: slog.LevelInfo + 2, Level: WarningSeverity}},
+			level:    slog.LevelInfo + 2,
+			want:     WarningSeverity,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(buf, &HandlerOptions{Level: V(10), LevelMap: test.levelMap, Terminate: logger.LogOnly})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got := Severity(buf.Bytes()[0]); got != test.want {
+				t.Errorf("got %c, want %c", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVerbosity(t *testing.T) {
+	h := NewHandler(io.Discard, &HandlerOptions{Level: V(2)})
+	for v, want := range map[int]bool{0: true, 1: true, 2: true, 3: false} {
+		if got := h.Enabled(context.Background(), V(v)); got != want {
+			t.Errorf("V(%d): got %t, want %t", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package klog
+
+import (
+	"log/slog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// Severity is the letter that starts the header of a klog line.
+type Severity byte
+
+const (
+	InfoSeverity    Severity = 'I'
+	WarningSeverity Severity = 'W'
+	ErrorSeverity   Severity = 'E'
+	FatalSeverity   Severity = 'F'
+)
+
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// klog has no panic level, logger.LevelPanic is mapped to ErrorSeverity.
+var defaultLevelMap = logger.LevelMap[Severity]{
+	{Min: logger.LevelTrace, Level: InfoSeverity},
+	{Min: logger.LevelWarn, Level: WarningSeverity},
+	{Min: logger.LevelError, Level: ErrorSeverity},
+	{Min: logger.LevelFatal, Level: FatalSeverity},
+}
+
+// V returns the slog level of the klog verbosity v, -v, as logr.ToSlogHandler maps V-levels,
+// so that klog.V(4) is slog.LevelDebug.
+// Records below slog.LevelInfo are written with InfoSeverity, and HandlerOptions.Level
+// set to V(n) writes the records up to verbosity n, as the -v flag of klog does.
+func V(v int) slog.Level {
+	return slog.Level(-v)
+}
@@ -0,0 +1,36 @@
+package logger
+
+import "log/slog"
+
+// LevelRange maps the slog levels at or above Min, up to the Min of the next range, to Level.
+type LevelRange[T any] struct {
+	Min   slog.Level
+	Level T
+}
+
+// LevelMap maps slog levels to the levels of a backend, the ranges do not need to be sorted.
+//
+// For example, with the ranges {LevelWarn, warn} and {LevelError, error},
+// anything at or above LevelWarn but below LevelError is mapped to warn.
+// Levels below every range are mapped to the lowest range.
+type LevelMap[T any] []LevelRange[T]
+
+// Map returns the level of the range with the highest Min at or below level.
+func (m LevelMap[T]) Map(level slog.Level) (rt T) {
+	best, lowest := -1, -1
+	for i, r := range m {
+		if r.Min <= level && (best < 0 || r.Min > m[best].Min) {
+			best = i
+		}
+		if lowest < 0 || r.Min < m[lowest].Min {
+			lowest = i
+		}
+	}
+	if best < 0 {
+		best = lowest
+	}
+	if best < 0 {
+		return rt
+	}
+	return m[best].Level
+}
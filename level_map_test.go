@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelMap(t *testing.T) {
+	m := LevelMap[string]{
+		{Min: LevelError, Level: "error"},
+		{Min: LevelDebug, Level: "debug"},
+		{Min: LevelInfo, Level: "info"},
+		{Min: LevelWarn, Level: "warn"},
+	}
+
+	for level, want := range map[slog.Level]string{
+		LevelTrace:     "debug",
+		LevelDebug:     "debug",
+		LevelInfo - 1:  "debug",
+		LevelInfo:      "info",
+		LevelInfo + 2:  "info",
+		LevelWarn - 1:  "info",
+		LevelWarn:      "warn",
+		LevelError:     "error",
+		LevelFatal + 4: "error",
+	} {
+		assert.Equal(t, want, m.Map(level), "level %s", level)
+	}
+
+	t.Run("empty map", func(t *testing.T) {
+		assert.Equal(t, "", LevelMap[string]{}.Map(LevelInfo))
+	})
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"syscall"
+)
+
+// Syncer is implemented by handlers which can flush the output buffered by their backend.
+type Syncer interface {
+	Sync() error
+}
+
+// Closer is implemented by handlers which own their output,
+// Close flushes and closes it, the handler and the handlers derived from it must not be used afterwards.
+type Closer interface {
+	Close() error
+}
+
+// Sync flushes h before the program exits.
+//
+// If h is a Syncer, its Sync is called, otherwise Sync is called on the handlers which h wraps,
+// as returned by a method Unwrap() slog.Handler or Unwrap() []slog.Handler.
+// A handler that is neither a Syncer nor a wrapper is skipped.
+func Sync(h slog.Handler) error {
+	return walk(h, func(h slog.Handler) (bool, error) {
+		if s, ok := h.(Syncer); ok {
+			return true, s.Sync()
+		}
+		return false, nil
+	})
+}
+
+// Close closes h, it is like Sync with Closer.
+func Close(h slog.Handler) error {
+	return walk(h, func(h slog.Handler) (bool, error) {
+		if c, ok := h.(Closer); ok {
+			return true, c.Close()
+		}
+		return false, nil
+	})
+}
+
+// walk calls f on h, and on the handlers which h wraps unless f is done with h
+func walk(h slog.Handler, f func(slog.Handler) (bool, error)) error {
+	if h == nil {
+		return nil
+	}
+	if done, err := f(h); done {
+		return err
+	}
+	switch w := h.(type) {
+	case interface{ Unwrap() slog.Handler }:
+		return walk(w.Unwrap(), f)
+	case interface{ Unwrap() []slog.Handler }:
+		var errs []error
+		for _, h := range w.Unwrap() {
+			errs = append(errs, walk(h, f))
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// SyncWriter syncs w if it is a Syncer, such as *os.File.
+// The errors of files which cannot be synced, such as terminals and pipes, are ignored.
+func SyncWriter(w io.Writer) error {
+	s, ok := w.(Syncer)
+	if !ok {
+		return nil
+	}
+	if err := s.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTTY) {
+		return err
+	}
+	return nil
+}
+
+// CloseWriter closes w if it is an io.Closer,
+// except os.Stdout and os.Stderr, which are still used by the rest of the process.
+func CloseWriter(w io.Writer) error {
+	if w == io.Writer(os.Stdout) || w == io.Writer(os.Stderr) {
+		return nil
+	}
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
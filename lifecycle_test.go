@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncHandler struct {
+	slog.Handler
+	synced, closed int
+	err            error
+}
+
+func (h *syncHandler) Sync() error {
+	h.synced++
+	return h.err
+}
+
+func (h *syncHandler) Close() error {
+	h.closed++
+	return h.err
+}
+
+type wrapHandler struct {
+	slog.Handler
+}
+
+func (h wrapHandler) Unwrap() slog.Handler { return h.Handler }
+
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(context.Context, slog.Level) bool  { return true }
+func (h fanoutHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h fanoutHandler) WithAttrs([]slog.Attr) slog.Handler        { return h }
+func (h fanoutHandler) WithGroup(string) slog.Handler             { return h }
+func (h fanoutHandler) Unwrap() []slog.Handler                    { return h }
+
+func TestSync(t *testing.T) {
+	t.Run("syncer", func(t *testing.T) {
+		h := &syncHandler{}
+		assert.NoError(t, Sync(h))
+		assert.Equal(t, 1, h.synced)
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		h := &syncHandler{}
+		assert.NoError(t, Sync(wrapHandler{wrapHandler{h}}))
+		assert.Equal(t, 1, h.synced)
+	})
+
+	t.Run("a syncer wrapper is not unwrapped", func(t *testing.T) {
+		inner := &syncHandler{}
+		outer := &syncHandler{Handler: wrapHandler{inner}}
+		assert.NoError(t, Sync(wrapHandler{outer}))
+		assert.Equal(t, 1, outer.synced)
+		assert.Equal(t, 0, inner.synced)
+	})
+
+	t.Run("fan-out", func(t *testing.T) {
+		first, second := &syncHandler{err: errors.New("first")}, &syncHandler{}
+		err := Sync(fanoutHandler{first, wrapHandler{second}, slog.NewTextHandler(nil, nil)})
+		assert.EqualError(t, err, "first")
+		assert.Equal(t, 1, first.synced)
+		assert.Equal(t, 1, second.synced)
+	})
+
+	t.Run("neither", func(t *testing.T) {
+		assert.NoError(t, Sync(slog.NewTextHandler(nil, nil)))
+		assert.NoError(t, Sync(nil))
+	})
+}
+
+func TestClose(t *testing.T) {
+	h := &syncHandler{}
+	assert.NoError(t, Close(wrapHandler{h}))
+	assert.Equal(t, 1, h.closed)
+	assert.Equal(t, 0, h.synced)
+}
+
+type syncWriter struct {
+	syncHandler
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestSyncWriter(t *testing.T) {
+	w := &syncWriter{}
+	assert.NoError(t, SyncWriter(w))
+	assert.Equal(t, 1, w.synced)
+
+	w.err = &fs.PathError{Op: "sync", Path: "/dev/stdout", Err: syscall.EINVAL}
+	assert.NoError(t, SyncWriter(w), "a terminal or a pipe cannot be synced")
+	w.err = &fs.PathError{Op: "sync", Path: "/dev/tty", Err: syscall.ENOTTY}
+	assert.NoError(t, SyncWriter(w))
+	w.err = errors.New("disk full")
+	assert.EqualError(t, SyncWriter(w), "disk full")
+
+	_, pw, err := os.Pipe()
+	assert.NoError(t, err)
+	defer pw.Close()
+	assert.NoError(t, SyncWriter(pw))
+
+	assert.NoError(t, SyncWriter(nil))
+}
+
+func TestCloseWriter(t *testing.T) {
+	w := &syncWriter{}
+	assert.NoError(t, CloseWriter(w))
+	assert.Equal(t, 1, w.closed)
+
+	assert.NoError(t, CloseWriter(os.Stderr))
+	assert.NoError(t, CloseWriter(os.Stdout))
+	_, err := os.Stderr.Stat()
+	assert.NoError(t, err, "stderr must stay open")
+
+	assert.NoError(t, CloseWriter(nil))
+}
@@ -0,0 +1,267 @@
+package log15
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gopkg.in/inconshreveable/log15.v2"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/helper"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
+
+type Handler struct {
+	handler   log15.Handler
+	addSource bool
+	level     *slog.LevelVar
+	attrGroup *helper.AttrGroup
+	levelMap  logger.LevelMap[log15.Lvl]
+	replace   func(groups []string, a slog.Attr) slog.Attr
+	keys      logger.Keys
+	dupPolicy logger.DuplicateKeyPolicy
+	terminate logger.TerminateFunc
+	ctxAttrs  []logger.ContextExtractor
+	stack     slog.Leveler
+}
+
+type HandlerOptions struct {
+	AddSource       bool
+	Level           slog.Level
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps slog levels to the levels of log15,
+	// by default the levels below slog.LevelInfo are LvlDebug, and logger.LevelPanic and above are LvlCrit.
+	LevelMap logger.LevelMap[log15.Lvl]
+
+	// ReplaceAttr follows helper.Builtins,
+	// a time which is replaced by another kind of value or renamed is passed in the context of the record.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the built-in attributes,
+	// the keys of the time, the level and the message are the KeyNames of the log15.Record,
+	// the defaults are the keys of log15.Logger, "t", "lvl" and "msg",
+	// and "caller" and "fn" for the source, as log15.CallerFileHandler and log15.CallerFuncHandler add it.
+	Keys logger.Keys
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+	Terminate          logger.TerminateFunc
+	ExitFunc           func(code int)
+}
+
+// NewHandler passes the records to a log15.Handler, such as the handler chain of a log15.Logger,
+// which is returned by its GetHandler method.
+//
+// The attrs are the context of the log15.Record, as key/value pairs, the keys in groups are prefixed by the group keys.
+// The Call of the record is left zero, as a stack.Call cannot be built from Record.PC,
+// so log15.CallerFileHandler and the like do not apply, the source is passed in the context when AddSource is true.
+// log15 writes the time of a record even when it is zero.
+func NewHandler(handler log15.Handler, options *HandlerOptions) *Handler {
+	levelar := &slog.LevelVar{}
+	levelar.Set(options.Level)
+
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
+	return &Handler{
+		handler:   handler,
+		addSource: options.AddSource,
+		level:     levelar,
+		attrGroup: &helper.AttrGroup{},
+		levelMap:  levelMap,
+		replace:   options.ReplaceAttr,
+		dupPolicy: options.DuplicateKeyPolicy,
+		terminate: terminate,
+		ctxAttrs:  options.ContextExtractors,
+		stack:     options.StacktraceLevel,
+		keys:      options.Keys.WithDefaults(defaultKeys),
+	}
+}
+
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		handler:   h.handler,
+		addSource: h.addSource,
+		level:     h.level,
+		attrGroup: h.attrGroup,
+		levelMap:  h.levelMap,
+		replace:   h.replace,
+		dupPolicy: h.dupPolicy,
+		terminate: h.terminate,
+		ctxAttrs:  h.ctxAttrs,
+		stack:     h.stack,
+		keys:      h.keys,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		return level >= override
+	}
+	return level >= h.level.Level()
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle methods that produce output should observe the following rules:
+//   - If r.Time is the zero time, ignore the time.
+//   - If r.PC is zero, ignore it.
+//   - Attr's values should be resolved.
+//   - If an Attr's key and value are both the zero value, ignore the Attr.
+//     This can be tested with attr.Equal(Attr{}).
+//   - If a group's key is empty, inline the group's Attrs.
+//   - If a group has no Attrs (even if it has a non-empty key),
+//     ignore it.
+//
+// The error of the log15.Handler is returned.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+
+	attrs, err := helper.Normalize(h.attrGroup.WithAttrs(recordAttrs).Attrs(), h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
+
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+
+	keysAndValues := make([]any, 0, 2*len(attrs)+8)
+	var t time.Time
+	if b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime {
+		t = b.Time.Value.Time()
+	} else if b.Time.Key != "" {
+		keysAndValues = append(keysAndValues, b.Time.Key, b.Time.Value.Any())
+	}
+
+	keysAndValues = attrs2KeysAndValues(keysAndValues, "", attrs)
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		if h.keys.Source != "" {
+			keysAndValues = append(keysAndValues,
+				h.keys.Source+".function", src.Function,
+				h.keys.Source+".file", src.File,
+				h.keys.Source+".line", src.Line)
+		} else {
+			keysAndValues = append(keysAndValues, h.keys.File, fmt.Sprintf("%s:%d", src.File, src.Line), h.keys.Func, src.Function)
+		}
+	} else if b.Source.Key != "" {
+		keysAndValues = append(keysAndValues, b.Source.Key, b.Source.Value.Any())
+	}
+
+	if h.stack != nil && b.Level >= h.stack.Level() {
+		keysAndValues = append(keysAndValues, stackKey, helper.Stack(r.PC, 1))
+	}
+
+	err = h.handler.Log(&log15.Record{
+		Time: t,
+		Lvl:  h.levelMap.Map(b.Level),
+		Msg:  b.Message,
+		Ctx:  keysAndValues,
+		KeyNames: log15.RecordKeyNames{
+			Time: h.keys.Time,
+			Msg:  h.keys.Message,
+			Lvl:  h.keys.Level,
+		},
+	})
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
+	return err
+}
+
+// attrs2KeysAndValues appends normalized attrs to keysAndValues, the keys in groups are prefixed by the group keys
+func attrs2KeysAndValues(keysAndValues []any, prefix string, attrs []slog.Attr) []any {
+	for _, attr := range helper.Flatten(nil, prefix, attrs) {
+		keysAndValues = append(keysAndValues, attr.Key, attr.Value.Any())
+	}
+	return keysAndValues
+}
+
+// Sync flushes the log15.Handler if it has a Sync method.
+func (h *Handler) Sync() error {
+	if s, ok := h.handler.(logger.Syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Close closes the log15.Handler if it has a Close method, such as the handlers of log15.FileHandler and log15.NetHandler.
+func (h *Handler) Close() error {
+	if c, ok := h.handler.(logger.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	return cp
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	return cp
+}
@@ -0,0 +1,347 @@
+package log15
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/inconshreveable/log15.v2"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+/*
+	This unit test is adapted from go 1.21.1 log/slog/handler_test.go
+	with slight modifications to test the log15 handler
+*/
+
+// header is the logfmt of a record without time, as log15.LogfmtFormat writes it
+const header = "t=0001-01-01T00:00:00+0000 lvl=info msg=message"
+
+func TestDefaultHandle(t *testing.T) {
+	ctx := context.Background()
+	preAttrs := []slog.Attr{slog.Int("pre", 0)}
+	attrs := []slog.Attr{slog.Int("a", 1), slog.String("b", "two")}
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "no attrs",
+			want: "",
+		},
+		{
+			name:  "attrs",
+			attrs: attrs,
+			want:  " a=1 b=two",
+		},
+		{
+			name:  "preformatted",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs) },
+			attrs: attrs,
+			want:  " pre=0 a=1 b=two",
+		},
+		{
+			name: "groups",
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g",
+					slog.Int("b", 2),
+					slog.Group("h", slog.Int("c", 3)),
+					slog.Int("d", 4)),
+				slog.Int("e", 5),
+			},
+			want: " a=1 g.b=2 g.h.c=3 g.d=4 e=5",
+		},
+		{
+			name:  "group",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
+			attrs: attrs,
+			want:  " pre=0 s.a=1 s.b=two",
+		},
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  " p1=1 s1.p2=2 s1.s2.a=1 s1.s2.b=two",
+		},
+		{
+			name: "two with-groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  " p1=1 s1.s2.a=1 s1.s2.b=two",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+
+			buf := &bytes.Buffer{}
+
+			var h slog.Handler = NewHandler(log15.StreamHandler(buf, log15.LogfmtFormat()), &HandlerOptions{})
+
+			if test.with != nil {
+				h = test.with(h)
+			}
+
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			got = buf.String()
+			if want := header + test.want + "\n"; got != want {
+				t.Errorf("\ngot  %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	_, file, line, _ := runtime.Caller(0)
+
+	var got *log15.Record
+	h := NewHandler(log15.FuncHandler(func(r *log15.Record) error {
+		got = r
+		return nil
+	}), &HandlerOptions{AddSource: true})
+	now := time.Now()
+	r := slog.NewRecord(now, slog.LevelWarn, "message", pcs[0])
+	r.AddAttrs(slog.Any("err", io.EOF))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Time.Equal(now) || got.Lvl != log15.LvlWarn || got.Msg != "message" {
+		t.Errorf("got time %v, level %v and message %q", got.Time, got.Lvl, got.Msg)
+	}
+	if want := (log15.RecordKeyNames{Time: "t", Msg: "msg", Lvl: "lvl"}); got.KeyNames != want {
+		t.Errorf("got key names %+v, want %+v", got.KeyNames, want)
+	}
+	want := fmt.Sprint([]any{"err", io.EOF,
+		callerKey, fmt.Sprintf("%s:%d", file, line-1),
+		fnKey, "github.com/m40Jc001/slog-handler-adapter/log15.TestRecord"})
+	if got := fmt.Sprint(got.Ctx); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestHandlerError(t *testing.T) {
+	h := NewHandler(log15.FuncHandler(func(r *log15.Record) error {
+		return io.ErrShortWrite
+	}), &HandlerOptions{})
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)); err != io.ErrShortWrite {
+		t.Errorf("want the error of the log15 handler, got %v", err)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	count := 1000
+	var buf bytes.Buffer
+	h := NewHandler(log15.StreamHandler(&buf, log15.LogfmtFormat()), &HandlerOptions{})
+	sub1 := h.WithAttrs([]slog.Attr{slog.Bool("sub1", true)})
+	sub2 := h.WithAttrs([]slog.Attr{slog.Bool("sub2", true)})
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		sub1Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub1", 0)
+		sub1Record.AddAttrs(slog.Int("i", i))
+		sub2Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub2", 0)
+		sub2Record.AddAttrs(slog.Int("i", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sub1.Handle(ctx, sub1Record); err != nil {
+				t.Error(err)
+			}
+			if err := sub2.Handle(ctx, sub2Record); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 1; i <= 2; i++ {
+		want := "hello from sub" + strconv.Itoa(i)
+		n := strings.Count(buf.String(), want)
+		if n != count {
+			t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	keys := map[string]string{timeKey: slog.TimeKey, lvlKey: slog.LevelKey, callerKey: slog.SourceKey}
+	conformance.Run(t, conformance.Backend{
+		NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+			return NewHandler(log15.StreamHandler(w, log15.JsonFormat()), &HandlerOptions{AddSource: addSource})
+		},
+		Parse: func(out []byte) ([]map[string]any, error) {
+			ms, err := conformance.ParseJSON(out)
+			for i := range ms {
+				ms[i] = conformance.Unflatten(conformance.Rename(ms[i], keys), ".")
+				// log15 always writes a time, the zero time stands for a record without time, see TestZeroTime
+				if ms[i][slog.TimeKey] == "0001-01-01T00:00:00Z" {
+					delete(ms[i], slog.TimeKey)
+				}
+			}
+			return ms, err
+		},
+		// the stream handler of log15 does not sync nor close its writer, see TestSyncClose
+		NewHandlerWithOptions: func(w io.Writer, options conformance.Options) slog.Handler {
+			return NewHandler(log15.StreamHandler(w, log15.JsonFormat()), &HandlerOptions{
+				Level:              options.Level,
+				StacktraceLevel:    options.StacktraceLevel,
+				ReplaceAttr:        options.ReplaceAttr,
+				DuplicateKeyPolicy: options.DuplicateKeyPolicy,
+				ContextExtractors:  options.ContextExtractors,
+				Terminate:          options.Terminate,
+				ExitFunc:           options.ExitFunc,
+			})
+		},
+	})
+}
+
+// TestZeroTime shows how log15 writes a record without time, the formats of log15 always write the time.
+func TestZeroTime(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(log15.StreamHandler(buf, log15.LogfmtFormat()), &HandlerOptions{})
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)); err != nil {
+		t.Fatal(err)
+	}
+	want := "t=0001-01-01T00:00:00+0000 lvl=info msg=message\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[log15.Lvl]
+		level    slog.Level
+		want     log15.Lvl
+	}{
+		{name: "trace", level: logger.LevelTrace, want: log15.LvlDebug},
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: log15.LvlInfo},
+		{name: "panic", level: logger.LevelPanic, want: log15.LvlCrit},
+		{name: "fatal", level: logger.LevelFatal, want: log15.LvlCrit},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[log15.Lvl]{{Min: slog.LevelDebug, Level: log15.LvlDebug}, {Min: slog.LevelInfo + 2, Level: log15.LvlWarn}},
+			level:    slog.LevelInfo + 2,
+			want:     log15.LvlWarn,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got log15.Lvl
+			h := NewHandler(log15.FuncHandler(func(r *log15.Record) error {
+				got = r.Lvl
+				return nil
+			}), &HandlerOptions{Level: logger.LevelTrace, LevelMap: test.levelMap, Terminate: logger.LogOnly})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLvlFilterHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := slog.New(NewHandler(log15.LvlFilterHandler(log15.LvlWarn, log15.StreamHandler(buf, log15.LogfmtFormat())), &HandlerOptions{}))
+	l.Info("filtered")
+	l.Warn("kept")
+	if strings.Contains(buf.String(), "filtered") || !strings.Contains(buf.String(), "msg=kept") {
+		t.Errorf("want the records below warn filtered by log15, got %s", buf.String())
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(log15.StreamHandler(buf, log15.JsonFormat()), &HandlerOptions{AddSource: true, Keys: logger.Keys{
+		Time:    "@timestamp",
+		Level:   "severity",
+		Message: "message",
+		Source:  "source",
+	}})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := conformance.Unflatten(ms[0], ".")
+	for key, want := range map[string]any{"@timestamp": "2023-10-16T12:00:00Z", "severity": "info", "message": "hello"} {
+		if got[key] != want {
+			t.Errorf("%q: got %v, want %v", key, got[key], want)
+		}
+	}
+	src, _ := got["source"].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/log15.TestKeys"; src["function"] != want {
+		t.Errorf("source: got %v, want function %s", got["source"], want)
+	}
+}
+
+// closingHandler records the calls of Sync and Close
+type closingHandler struct {
+	log15.Handler
+	synced, closed int
+}
+
+func (h *closingHandler) Sync() error {
+	h.synced++
+	return nil
+}
+
+func (h *closingHandler) Close() error {
+	h.closed++
+	return nil
+}
+
+func TestSyncClose(t *testing.T) {
+	ch := &closingHandler{Handler: log15.DiscardHandler()}
+	h := NewHandler(ch, &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g")
+	if err := logger.Sync(h); err != nil {
+		t.Fatal(err)
+	}
+	if ch.synced != 1 || ch.closed != 0 {
+		t.Errorf("after Sync: synced %d times, closed %d times", ch.synced, ch.closed)
+	}
+	if err := logger.Close(h); err != nil {
+		t.Fatal(err)
+	}
+	if ch.synced != 1 || ch.closed != 1 {
+		t.Errorf("after Close: synced %d times, closed %d times", ch.synced, ch.closed)
+	}
+}
@@ -0,0 +1,22 @@
+package log15
+
+import (
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+const timeKey string = "t"
+const lvlKey string = "lvl"
+const msgKey string = "msg"
+const callerKey string = "caller"
+const fnKey string = "fn"
+const stackKey string = "stack"
+
+// defaultKeys are the key names of the records of log15.Logger,
+// and the keys of log15.CallerFileHandler and log15.CallerFuncHandler for the source
+var defaultKeys = logger.Keys{
+	Time:    timeKey,
+	Level:   lvlKey,
+	Message: msgKey,
+	File:    callerKey,
+	Func:    fnKey,
+}
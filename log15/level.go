@@ -0,0 +1,17 @@
+package log15
+
+import (
+	"gopkg.in/inconshreveable/log15.v2"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// log15 has no level below LvlDebug, and LvlCrit is its highest level.
+var defaultLevelMap = logger.LevelMap[log15.Lvl]{
+	{Min: logger.LevelTrace, Level: log15.LvlDebug},
+	{Min: logger.LevelInfo, Level: log15.LvlInfo},
+	{Min: logger.LevelWarn, Level: log15.LvlWarn},
+	{Min: logger.LevelError, Level: log15.LvlError},
+	{Min: logger.LevelPanic, Level: log15.LvlCrit},
+}
@@ -0,0 +1,302 @@
+package logr
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-logr/logr"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/helper"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+
+type Handler struct {
+	sink      logr.LogSink
+	addSource bool
+	isJSON    bool
+	level     *slog.LevelVar
+	attrGroup *helper.AttrGroup
+	levelMap  logger.LevelMap[int]
+	replace   func(groups []string, a slog.Attr) slog.Attr
+	keys      logger.Keys
+	dupPolicy logger.DuplicateKeyPolicy
+	terminate logger.TerminateFunc
+	ctxAttrs  []logger.ContextExtractor
+	stack     slog.Leveler
+}
+
+type HandlerOptions struct {
+	AddSource bool
+
+	// JSONFormatter passes groups as nested maps, for sinks that write JSON,
+	// by default the keys in groups are prefixed by the group keys.
+	JSONFormatter bool
+
+	Level           slog.Level
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps the slog levels below logger.LevelError to logr V-levels,
+	// by default a level below LevelInfo is the V-level -level, as logr.ToSlogHandler does,
+	// and the levels from LevelInfo are V(0).
+	// Records at or above logger.LevelError are passed to LogSink.Error, which has no V-level.
+	LevelMap logger.LevelMap[int]
+
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the built-in attributes,
+	// the sink writes the level and the message itself, so their keys do not apply,
+	// the defaults are "ts", "caller" and "func".
+	Keys logger.Keys
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+	Terminate          logger.TerminateFunc
+	ExitFunc           func(code int)
+}
+
+// NewHandler writes the records to a logr.LogSink, it calls the Init method of the sink, as logr.New does.
+// The time of the record is passed as a value, so a sink that adds its own timestamp should have it turned off.
+//
+// Records below logger.LevelError are passed to Info with the V-level of LevelMap,
+// and only when the sink is enabled at that V-level.
+// Records at or above logger.LevelError are passed to Error,
+// with the error of a top-level "err" attr, as logr.Logger.Error takes it, or nil.
+func NewHandler(sink logr.LogSink, options *HandlerOptions) *Handler {
+	return NewHandlerFromLogger(logr.New(sink), options)
+}
+
+// NewHandlerFromLogger writes the records to the sink of an existing logr.Logger,
+// the values and the names of the logger are kept, a logger without sink discards the records.
+func NewHandlerFromLogger(l logr.Logger, options *HandlerOptions) *Handler {
+	levelar := &slog.LevelVar{}
+	levelar.Set(options.Level)
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
+	return &Handler{
+		sink:      l.GetSink(),
+		addSource: options.AddSource,
+		level:     levelar,
+		attrGroup: &helper.AttrGroup{},
+		levelMap:  options.LevelMap,
+		replace:   options.ReplaceAttr,
+		dupPolicy: options.DuplicateKeyPolicy,
+		terminate: terminate,
+		ctxAttrs:  options.ContextExtractors,
+		stack:     options.StacktraceLevel,
+		keys:      options.Keys.WithDefaults(defaultKeys),
+		isJSON:    options.JSONFormatter,
+	}
+}
+
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		sink:      h.sink,
+		addSource: h.addSource,
+		level:     h.level,
+		isJSON:    h.isJSON,
+		attrGroup: h.attrGroup,
+		levelMap:  h.levelMap,
+		replace:   h.replace,
+		dupPolicy: h.dupPolicy,
+		terminate: h.terminate,
+		ctxAttrs:  h.ctxAttrs,
+		stack:     h.stack,
+		keys:      h.keys,
+	}
+}
+
+// verbosity returns the V-level of a slog level below logger.LevelError
+func (h *Handler) verbosity(level slog.Level) int {
+	if len(h.levelMap) == 0 {
+		return verbosity(level)
+	}
+	return h.levelMap.Map(level)
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler.
+// Below logger.LevelError, the sink must also be enabled at the V-level of the record.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		if level < override {
+			return false
+		}
+	} else if level < h.level.Level() {
+		return false
+	}
+	if h.sink == nil {
+		return false
+	}
+	return level >= logger.LevelError || h.sink.Enabled(h.verbosity(level))
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle methods that produce output should observe the following rules:
+//   - If r.Time is the zero time, ignore the time.
+//   - If r.PC is zero, ignore it.
+//   - Attr's values should be resolved.
+//   - If an Attr's key and value are both the zero value, ignore the Attr.
+//     This can be tested with attr.Equal(Attr{}).
+//   - If a group's key is empty, inline the group's Attrs.
+//   - If a group has no Attrs (even if it has a non-empty key),
+//     ignore it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+
+	attrs, err := helper.Normalize(h.attrGroup.WithAttrs(recordAttrs).Attrs(), h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
+
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+
+	var recordErr error
+	if b.Level >= logger.LevelError {
+		for i, attr := range attrs {
+			if e, ok := attr.Value.Any().(error); ok && attr.Key == errKey {
+				recordErr = e
+				attrs = append(attrs[:i:i], attrs[i+1:]...)
+				break
+			}
+		}
+	}
+
+	keysAndValues := make([]any, 0, 2*len(attrs)+8)
+	switch {
+	case b.Time.Key == slog.TimeKey:
+		keysAndValues = append(keysAndValues, h.keys.Time, b.Time.Value.Any())
+	case b.Time.Key != "":
+		keysAndValues = append(keysAndValues, b.Time.Key, b.Time.Value.Any())
+	}
+
+	if h.isJSON {
+		keysAndValues = attrs2JSONKeysAndValues(keysAndValues, attrs)
+	} else {
+		keysAndValues = attrs2TextKeysAndValues(keysAndValues, "", attrs)
+	}
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		switch {
+		case h.keys.Source != "" && h.isJSON:
+			keysAndValues = append(keysAndValues, h.keys.Source, map[string]any{"function": src.Function, "file": src.File, "line": src.Line})
+		case h.keys.Source != "":
+			keysAndValues = append(keysAndValues,
+				h.keys.Source+".function", src.Function,
+				h.keys.Source+".file", src.File,
+				h.keys.Source+".line", src.Line)
+		default:
+			keysAndValues = append(keysAndValues, h.keys.File, fmt.Sprintf("%s:%d", src.File, src.Line), h.keys.Func, src.Function)
+		}
+	} else if b.Source.Key != "" {
+		keysAndValues = append(keysAndValues, b.Source.Key, b.Source.Value.Any())
+	}
+
+	if h.stack != nil && b.Level >= h.stack.Level() {
+		keysAndValues = append(keysAndValues, stackKey, helper.Stack(r.PC, 1))
+	}
+
+	switch v := h.verbosity(b.Level); {
+	case h.sink == nil:
+	case b.Level >= logger.LevelError:
+		h.sink.Error(recordErr, b.Message, keysAndValues...)
+	case h.sink.Enabled(v):
+		h.sink.Info(v, b.Message, keysAndValues...)
+	}
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
+	return nil
+}
+
+// attrs2TextKeysAndValues appends normalized attrs to keysAndValues, the keys in groups are prefixed by the group keys
+func attrs2TextKeysAndValues(keysAndValues []any, prefix string, attrs []slog.Attr) []any {
+	for _, attr := range helper.Flatten(nil, prefix, attrs) {
+		keysAndValues = append(keysAndValues, attr.Key, attr.Value.Any())
+	}
+	return keysAndValues
+}
+
+// attrs2JSONKeysAndValues appends normalized attrs to keysAndValues, groups become nested maps
+func attrs2JSONKeysAndValues(keysAndValues []any, attrs []slog.Attr) []any {
+	for _, attr := range attrs {
+		keysAndValues = append(keysAndValues, attr.Key, helper.NestedValue(attr.Value))
+	}
+	return keysAndValues
+}
+
+// Sync flushes the sink if it has a Sync method,
+// the sink is owned by the caller and is never closed by the handler.
+func (h *Handler) Sync() error {
+	if s, ok := h.sink.(logger.Syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	return cp
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	return cp
+}
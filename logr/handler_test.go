@@ -0,0 +1,333 @@
+package logr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+/*
+	This unit test is adapted from go 1.21.1 log/slog/handler_test.go
+	with slight modifications to test the logr handler
+*/
+
+// newFuncr returns a funcr logger writing a line per call to w
+func newFuncr(w io.Writer, isJSON bool, opts funcr.Options) logr.Logger {
+	var mu sync.Mutex
+	if isJSON {
+		return funcr.NewJSON(func(obj string) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintln(w, obj)
+		}, opts)
+	}
+	return funcr.New(func(prefix, args string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintln(w, args)
+	}, opts)
+}
+
+func TestDefaultHandle(t *testing.T) {
+	ctx := context.Background()
+	preAttrs := []slog.Attr{slog.Int("pre", 0)}
+	attrs := []slog.Attr{slog.Int("a", 1), slog.String("b", "two")}
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "no attrs",
+			want: `"level"=0 "msg"="message"` + "\n",
+		},
+		{
+			name:  "attrs",
+			attrs: attrs,
+			want:  `"level"=0 "msg"="message" "a"=1 "b"="two"` + "\n",
+		},
+		{
+			name:  "preformatted",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs) },
+			attrs: attrs,
+			want:  `"level"=0 "msg"="message" "pre"=0 "a"=1 "b"="two"` + "\n",
+		},
+		{
+			name: "groups",
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g",
+					slog.Int("b", 2),
+					slog.Group("h", slog.Int("c", 3)),
+					slog.Int("d", 4)),
+				slog.Int("e", 5),
+			},
+			want: `"level"=0 "msg"="message" "a"=1 "g.b"=2 "g.h.c"=3 "g.d"=4 "e"=5` + "\n",
+		},
+		{
+			name:  "group",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
+			attrs: attrs,
+			want:  `"level"=0 "msg"="message" "pre"=0 "s.a"=1 "s.b"="two"` + "\n",
+		},
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  `"level"=0 "msg"="message" "p1"=1 "s1.p2"=2 "s1.s2.a"=1 "s1.s2.b"="two"` + "\n",
+		},
+		{
+			name: "two with-groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  `"level"=0 "msg"="message" "p1"=1 "s1.s2.a"=1 "s1.s2.b"="two"` + "\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+
+			buf := &bytes.Buffer{}
+
+			var h slog.Handler = NewHandlerFromLogger(newFuncr(buf, false, funcr.Options{}), &HandlerOptions{})
+
+			if test.with != nil {
+				h = test.with(h)
+			}
+
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			got = buf.String()
+			if got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJSONHandle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandlerFromLogger(newFuncr(buf, true, funcr.Options{}).WithName("app"), &HandlerOptions{JSONFormatter: true}).
+		WithAttrs([]slog.Attr{slog.Int("pre", 0)}).
+		WithGroup("s")
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelWarn, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.String("b", "two")))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	// funcr writes the keys of a map in random order
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "map[level:0 logger:app msg:message pre:0 s:map[a:1 g:map[b:two]] ts:2023-10-16 12:00:00 +0000 UTC]"
+	if got := fmt.Sprint(ms[0]); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+// call is a call of the methods of recordingSink
+type call struct {
+	level         int
+	err           error
+	msg           string
+	keysAndValues []any
+}
+
+// recordingSink records the calls of Info and Error, it is enabled up to verbosity
+type recordingSink struct {
+	verbosity int
+	calls     []call
+}
+
+func (s *recordingSink) Init(logr.RuntimeInfo)          {}
+func (s *recordingSink) Enabled(level int) bool         { return level <= s.verbosity }
+func (s *recordingSink) WithValues(...any) logr.LogSink { return s }
+func (s *recordingSink) WithName(string) logr.LogSink   { return s }
+
+func (s *recordingSink) Info(level int, msg string, keysAndValues ...any) {
+	s.calls = append(s.calls, call{level: level, msg: msg, keysAndValues: keysAndValues})
+}
+
+func (s *recordingSink) Error(err error, msg string, keysAndValues ...any) {
+	s.calls = append(s.calls, call{level: -1, err: err, msg: msg, keysAndValues: keysAndValues})
+}
+
+func TestLevels(t *testing.T) {
+	ctx := context.Background()
+	boom := errors.New("boom")
+	sink := &recordingSink{verbosity: 4}
+	h := NewHandler(sink, &HandlerOptions{Level: logger.LevelTrace})
+
+	if !h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want debug enabled at V(4)")
+	}
+	if h.Enabled(ctx, logger.LevelTrace) {
+		t.Error("want trace disabled at V(8)")
+	}
+
+	for _, level := range []slog.Level{slog.LevelInfo, slog.LevelWarn, slog.LevelDebug, logger.LevelTrace} {
+		if err := h.Handle(ctx, slog.NewRecord(time.Time{}, level, level.String(), 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := slog.NewRecord(time.Time{}, slog.LevelError, "failed", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Any(errKey, boom))
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprint([]call{
+		{level: 0, msg: "INFO", keysAndValues: []any{}},
+		{level: 0, msg: "WARN", keysAndValues: []any{}},
+		{level: 4, msg: "DEBUG", keysAndValues: []any{}},
+		{level: -1, err: boom, msg: "failed", keysAndValues: []any{"a", int64(1)}},
+	})
+	if got := fmt.Sprint(sink.calls); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+
+	sink = &recordingSink{verbosity: 1}
+	h = NewHandler(sink, &HandlerOptions{Level: logger.LevelTrace, LevelMap: logger.LevelMap[int]{
+		{Min: logger.LevelTrace, Level: 2},
+		{Min: logger.LevelDebug, Level: 1},
+		{Min: logger.LevelInfo, Level: 0},
+	}})
+	for _, level := range []slog.Level{slog.LevelDebug, logger.LevelTrace} {
+		if err := h.Handle(ctx, slog.NewRecord(time.Time{}, level, level.String(), 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want = fmt.Sprint([]call{{level: 1, msg: "DEBUG", keysAndValues: []any{}}})
+	if got := fmt.Sprint(sink.calls); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	count := 1000
+	for _, handlerType := range []string{"text", "json"} {
+		t.Run(handlerType, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewHandlerFromLogger(newFuncr(&buf, handlerType == "json", funcr.Options{}), &HandlerOptions{JSONFormatter: handlerType == "json"})
+			sub1 := h.WithAttrs([]slog.Attr{slog.Bool("sub1", true)})
+			sub2 := h.WithAttrs([]slog.Attr{slog.Bool("sub2", true)})
+			var wg sync.WaitGroup
+			for i := 0; i < count; i++ {
+				sub1Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub1", 0)
+				sub1Record.AddAttrs(slog.Int("i", i))
+				sub2Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub2", 0)
+				sub2Record.AddAttrs(slog.Int("i", i))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := sub1.Handle(ctx, sub1Record); err != nil {
+						t.Error(err)
+					}
+					if err := sub2.Handle(ctx, sub2Record); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			for i := 1; i <= 2; i++ {
+				want := "hello from sub" + strconv.Itoa(i)
+				n := strings.Count(buf.String(), want)
+				if n != count {
+					t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+				}
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	keys := map[string]string{timeKey: slog.TimeKey, callerKey: slog.SourceKey}
+	for _, isJSON := range []bool{false, true} {
+		isJSON := isJSON
+		t.Run(fmt.Sprintf("json=%t", isJSON), func(t *testing.T) {
+			conformance.Run(t, conformance.Backend{
+				NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+					l := newFuncr(w, true, funcr.Options{})
+					return NewHandlerFromLogger(l, &HandlerOptions{AddSource: addSource, JSONFormatter: isJSON})
+				},
+				Parse: func(out []byte) ([]map[string]any, error) {
+					ms, err := conformance.ParseJSON(out)
+					for i := range ms {
+						ms[i] = conformance.Rename(ms[i], keys)
+						if !isJSON {
+							ms[i] = conformance.Unflatten(ms[i], ".")
+						}
+					}
+					return ms, err
+				},
+				// the sink is owned by the caller, it is never closed
+				NewHandlerWithOptions: func(w io.Writer, options conformance.Options) slog.Handler {
+					l := newFuncr(w, true, funcr.Options{Verbosity: 10})
+					return NewHandlerFromLogger(l, &HandlerOptions{
+						JSONFormatter:      isJSON,
+						Level:              options.Level,
+						StacktraceLevel:    options.StacktraceLevel,
+						ReplaceAttr:        options.ReplaceAttr,
+						DuplicateKeyPolicy: options.DuplicateKeyPolicy,
+						ContextExtractors:  options.ContextExtractors,
+						Terminate:          options.Terminate,
+						ExitFunc:           options.ExitFunc,
+					})
+				},
+			})
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	buf := &bytes.Buffer{}
+	h := NewHandlerFromLogger(newFuncr(buf, true, funcr.Options{}), &HandlerOptions{AddSource: true, JSONFormatter: true, Keys: logger.Keys{
+		Source: "source",
+	}})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, _ := ms[0]["source"].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/logr.TestKeys"; src["function"] != want {
+		t.Errorf("source: got %v, want function %s", ms[0]["source"], want)
+	}
+}
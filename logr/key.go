@@ -0,0 +1,24 @@
+package logr
+
+import (
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+const timeKey string = "ts"
+const callerKey string = "caller"
+const funcKey string = "func"
+const stackKey string = "stack"
+
+// errKey is the key of the error of logr.LogSink.Error,
+// the Handler passes a top-level attr with this key and an error value to Error
+const errKey string = "err"
+
+// nameKey is the key of the names of logr.Logger.WithName, joined by "/"
+const nameKey string = "logger"
+
+// defaultKeys follows the keys of funcr, a logr.LogSink writes the level and the message itself
+var defaultKeys = logger.Keys{
+	Time: timeKey,
+	File: callerKey,
+	Func: funcKey,
+}
@@ -0,0 +1,20 @@
+package logr
+
+import (
+	"log/slog"
+)
+
+// verbosity is used when HandlerOptions.LevelMap is empty, it follows the mapping of logr.ToSlogHandler:
+// a slog level below LevelInfo is the V-level -level, so that LevelDebug is V(4),
+// levels at or above LevelInfo are V(0).
+func verbosity(level slog.Level) int {
+	if level >= slog.LevelInfo {
+		return 0
+	}
+	return -int(level)
+}
+
+// slogLevel is the inverse of verbosity.
+func slogLevel(v int) slog.Level {
+	return slog.Level(-v)
+}
@@ -0,0 +1,128 @@
+package logr
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+/*
+	implement logr.LogSink
+*/
+
+var _ logr.LogSink = (*LogSink)(nil)
+var _ logr.CallDepthLogSink = (*LogSink)(nil)
+
+// LogSink writes the logs of a logr.Logger to a slog.Handler, such as the handlers of the other packages,
+// so that code written against logr shares their backend.
+//
+// The V-level v is the slog level -v, as logr.FromSlogHandler does, Error logs at slog.LevelError
+// with the error under the "err" key, and the names of WithName are joined by "/" under the "logger" key.
+// The values of logr.Marshaler are replaced by the result of MarshalLog.
+type LogSink struct {
+	handler   slog.Handler
+	name      string
+	callDepth int
+}
+
+// NewLogSink returns a LogSink that writes to handler.
+func NewLogSink(handler slog.Handler) *LogSink {
+	return &LogSink{handler: handler}
+}
+
+// NewLogger returns a logr.Logger that writes to handler, see LogSink.
+func NewLogger(handler slog.Handler) logr.Logger {
+	return logr.New(NewLogSink(handler))
+}
+
+func (s *LogSink) clone() *LogSink {
+	return &LogSink{
+		handler:   s.handler,
+		name:      s.name,
+		callDepth: s.callDepth,
+	}
+}
+
+// Init receives the number of frames added by logr, to find the caller for the source of the records.
+func (s *LogSink) Init(info logr.RuntimeInfo) {
+	s.callDepth = info.CallDepth
+}
+
+// Enabled reports whether the handler is enabled at the slog level of the V-level.
+func (s *LogSink) Enabled(level int) bool {
+	return s.handler.Enabled(context.Background(), slogLevel(level))
+}
+
+// Info writes a record at the slog level of the V-level.
+func (s *LogSink) Info(level int, msg string, keysAndValues ...any) {
+	s.log(nil, slogLevel(level), msg, keysAndValues)
+}
+
+// Error writes a record at slog.LevelError, with err under the "err" key when it is not nil.
+func (s *LogSink) Error(err error, msg string, keysAndValues ...any) {
+	s.log(err, slog.LevelError, msg, keysAndValues)
+}
+
+// log is called by Info and Error, which are called by the logr.Logger methods
+func (s *LogSink) log(err error, level slog.Level, msg string, keysAndValues []any) {
+	ctx := context.Background()
+	if !s.handler.Enabled(ctx, level) {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3+s.callDepth, pcs[:]) // skip [runtime.Callers, log, Info or Error] and the frames of logr
+
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	if s.name != "" {
+		r.AddAttrs(slog.String(nameKey, s.name))
+	}
+	if err != nil {
+		r.AddAttrs(slog.Any(errKey, err))
+	}
+	r.AddAttrs(kvs2Attrs(keysAndValues)...)
+	_ = s.handler.Handle(ctx, r)
+}
+
+// kvs2Attrs converts the key/value pairs of logr as slog.Logger.Log does,
+// a key that is not a string is written under the "!BADKEY" key
+func kvs2Attrs(keysAndValues []any) []slog.Attr {
+	var r slog.Record
+	r.Add(keysAndValues...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		if m, ok := a.Value.Any().(logr.Marshaler); ok && a.Value.Kind() == slog.KindAny {
+			a.Value = slog.AnyValue(m.MarshalLog())
+		}
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// WithValues returns a LogSink whose handler has the key/value pairs as attrs.
+func (s *LogSink) WithValues(keysAndValues ...any) logr.LogSink {
+	cp := s.clone()
+	cp.handler = cp.handler.WithAttrs(kvs2Attrs(keysAndValues))
+	return cp
+}
+
+// WithName returns a LogSink whose name has name appended, separated by "/".
+func (s *LogSink) WithName(name string) logr.LogSink {
+	cp := s.clone()
+	if cp.name != "" {
+		name = cp.name + "/" + name
+	}
+	cp.name = name
+	return cp
+}
+
+// WithCallDepth returns a LogSink that skips depth more frames to find the caller.
+func (s *LogSink) WithCallDepth(depth int) logr.LogSink {
+	cp := s.clone()
+	cp.callDepth += depth
+	return cp
+}
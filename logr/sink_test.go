@@ -0,0 +1,85 @@
+package logr
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+	"github.com/m40Jc001/slog-handler-adapter/zerolog"
+)
+
+// ref implements logr.Marshaler
+type ref struct{ namespace, name string }
+
+func (r ref) MarshalLog() any { return r.namespace + "/" + r.name }
+
+func newJSONLogger(buf *bytes.Buffer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestLogSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewLogger(newJSONLogger(buf, slog.LevelDebug).Handler()).WithName("ctrl").WithValues("pod", ref{"ns", "p"})
+
+	l.Info("info", "a", 1)
+	_, file, line, _ := runtime.Caller(0)
+	l.V(4).Info("debug")
+	l.V(5).Info("dropped")
+	l.WithName("sub").Error(errors.New("boom"), "failed", "b", "two")
+	l.Info("bad", 1)
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 4 {
+		t.Fatalf("want 4 records, got %d: %s", len(ms), buf.String())
+	}
+	for i, want := range []map[string]any{
+		{"level": "INFO", "msg": "info", "logger": "ctrl", "pod": "ns/p", "a": float64(1)},
+		{"level": "DEBUG", "msg": "debug", "logger": "ctrl"},
+		{"level": "ERROR", "msg": "failed", "logger": "ctrl/sub", "err": "boom", "b": "two"},
+		{"level": "INFO", "msg": "bad", "!BADKEY": float64(1)},
+	} {
+		for key, value := range want {
+			if ms[i][key] != value {
+				t.Errorf("record %d, %q: got %v, want %v", i, key, ms[i][key], value)
+			}
+		}
+	}
+
+	src, _ := ms[0][slog.SourceKey].(map[string]any)
+	if src["file"] != file || src["line"] != float64(line-1) {
+		t.Errorf("want the source at %s:%d, got %v", filepath.Base(file), line-1, src)
+	}
+}
+
+func TestLogSinkEnabled(t *testing.T) {
+	l := NewLogger(newJSONLogger(&bytes.Buffer{}, slog.LevelInfo).Handler())
+	if !l.Enabled() || l.V(1).Enabled() {
+		t.Error("want only V(0) enabled at slog.LevelInfo")
+	}
+}
+
+func TestLogSinkAdapter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewLogger(zerolog.NewHandler(buf, &zerolog.HandlerOptions{})).WithValues("a", 1)
+	l.Info("message", "b", "two")
+	if got, want := buf.String(), " INF message a=1 b=two\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package logrus
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func BenchmarkHandleWithAttrs(b *testing.B) {
+	ctx := context.Background()
+	now := time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)
+	with := make([]slog.Attr, 0, 10)
+	for i := 0; i < cap(with); i++ {
+		with = append(with, slog.Int("with"+strconv.Itoa(i), i))
+	}
+	for _, bench := range []struct {
+		name   string
+		isJSON bool
+	}{
+		{name: "text"},
+		{name: "json", isJSON: true},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			h := NewHandler(io.Discard, &HandlerOptions{JSONFormatter: bench.isJSON}).
+				WithAttrs(with).
+				WithGroup("g").
+				WithAttrs([]slog.Attr{slog.String("request", "id")})
+			r := slog.NewRecord(now, slog.LevelInfo, "message", 0)
+			r.AddAttrs(slog.String("a", "b"), slog.Int("c", 1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := h.Handle(ctx, r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -2,14 +2,15 @@ package logrus
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"runtime"
 
 	"github.com/sirupsen/logrus"
 
+	logger "github.com/m40Jc001/slog-handler-adapter"
 	"github.com/m40Jc001/slog-handler-adapter/helper"
 )
 
@@ -18,23 +19,59 @@ import (
 */
 
 var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
 
 type Handler struct {
-	logr      *logrus.Logger
+	base      *logrus.Entry // the entry without the attrs of WithAttrs
+	logr      *logrus.Entry // the entry with the attrs of WithAttrs outside the groups, in JSON mode, or all of them
 	addSource bool
 	isJSON    bool
 	level     *slog.LevelVar
-	attrGroup *helper.AttrGroup
+	scope     *helper.Scope
+	prefix    string          // the keys of the open groups in text mode, joined by "."
+	fields    []logrus.Fields // the fields of WithAttrs in each open group in JSON mode
+	levelMap  logger.LevelMap[logrus.Level]
+	replace   func(groups []string, a slog.Attr) slog.Attr
+	keys      logger.Keys
+	dupPolicy logger.DuplicateKeyPolicy
+	terminate logger.TerminateFunc
+	ctxAttrs  []logger.ContextExtractor
+	stack     slog.Leveler
+
+	// fromLogger is set when the logger was supplied by the caller,
+	// the record time is then set on the logrus.Entry, so that the logger's formatter
+	// renders it with its own key and format instead of receiving it as a field
+	fromLogger bool
 }
 
 type HandlerOptions struct {
-	AddSource     bool
-	JSONFormatter bool
-	Level         slog.Level
+	AddSource       bool
+	JSONFormatter   bool
+	Level           slog.Level
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps slog levels to logrus levels,
+	// by default the levels between two constants of the root package are mapped to the lower one.
+	LevelMap logger.LevelMap[logrus.Level]
+
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the built-in attributes,
+	// the defaults are "timestamp", "level", "msg", "file" and "func".
+	Keys logger.Keys
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+
+	// Terminate does not depend on the logrus level, a record mapped to logrus.PanicLevel does not panic by itself.
+	Terminate logger.TerminateFunc
+
+	// ExitFunc is the Exit method of the logger by default, which runs the logrus exit handlers and the ExitFunc of the logger.
+	ExitFunc func(code int)
 }
 
 func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
-	logrus.New()
 	logr := &logrus.Logger{
 		Out:          writer,
 		Hooks:        make(logrus.LevelHooks),
@@ -42,31 +79,94 @@ func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
 		ExitFunc:     os.Exit,
 		ReportCaller: false, // always not use this, handle this feature at "Handle" function
 	}
+	keys := options.Keys.WithDefaults(defaultKeys)
+
+	// the time is written as a field, the formatter's time key is mapped to an empty key,
+	// which is never used by a field, so that logrus does not rename the time field on a clash
+	fieldMap := logrus.FieldMap{
+		logrus.FieldKeyTime:  "",
+		logrus.FieldKeyLevel: keys.Level,
+		logrus.FieldKeyMsg:   keys.Message,
+	}
+
 	if options.JSONFormatter {
-		logr.Formatter = &logrus.JSONFormatter{DisableTimestamp: true}
+		logr.Formatter = &logrus.JSONFormatter{DisableTimestamp: true, FieldMap: fieldMap}
 	} else {
-		logr.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+		logr.Formatter = &logrus.TextFormatter{DisableTimestamp: true, FieldMap: fieldMap}
 	}
 
+	return newHandler(logrus.NewEntry(logr), false, options)
+}
+
+// NewHandlerFromLogger wraps an existing *logrus.Logger and keeps its formatter, hooks, output and ExitFunc.
+//
+// The logger is shared and is not modified: a record is written only when both Level
+// and the level of the logger enable it, and when ReportCaller is set, logrus reports
+// a frame of this package as the caller, use AddSource for the source of the record.
+// The record time is set on the logrus.Entry, the formatter decides whether and how it is written,
+// a zero time is left to logrus, which uses the current time.
+// The formatter also decides the keys of the time, the level and the message, HandlerOptions.Keys only applies to the source.
+// JSONFormatter only selects how groups are converted to fields: nested logrus.Fields or dotted keys.
+func NewHandlerFromLogger(logr *logrus.Logger, options *HandlerOptions) *Handler {
+	return NewHandlerFromEntry(logrus.NewEntry(logr), options)
+}
+
+// NewHandlerFromEntry is like NewHandlerFromLogger, and also keeps the data fields of the entry.
+func NewHandlerFromEntry(entry *logrus.Entry, options *HandlerOptions) *Handler {
+	return newHandler(entry, true, options)
+}
+
+func newHandler(entry *logrus.Entry, fromLogger bool, options *HandlerOptions) *Handler {
 	levelar := &slog.LevelVar{}
 	levelar.Set(options.Level)
 
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	exit := options.ExitFunc
+	if exit == nil {
+		exit = entry.Logger.Exit
+	}
+	terminate := logger.DefaultTerminate(options.Terminate, exit)
+
 	return &Handler{
-		logr:      logr,
-		addSource: options.AddSource,
-		level:     levelar,
-		attrGroup: &helper.AttrGroup{},
-		isJSON:    options.JSONFormatter,
+		base:       entry,
+		logr:       entry,
+		addSource:  options.AddSource,
+		level:      levelar,
+		scope:      helper.NewScope(),
+		levelMap:   levelMap,
+		replace:    options.ReplaceAttr,
+		dupPolicy:  options.DuplicateKeyPolicy,
+		terminate:  terminate,
+		ctxAttrs:   options.ContextExtractors,
+		stack:      options.StacktraceLevel,
+		keys:       options.Keys.WithDefaults(defaultKeys),
+		isJSON:     options.JSONFormatter,
+		fromLogger: fromLogger,
 	}
 }
 
 func (h *Handler) clone() *Handler {
 	return &Handler{
-		logr:      h.logr,
-		addSource: h.addSource,
-		level:     h.level,
-		isJSON:    h.isJSON,
-		attrGroup: h.attrGroup,
+		base:       h.base,
+		logr:       h.logr,
+		addSource:  h.addSource,
+		level:      h.level,
+		isJSON:     h.isJSON,
+		scope:      h.scope,
+		prefix:     h.prefix,
+		fields:     h.fields,
+		levelMap:   h.levelMap,
+		replace:    h.replace,
+		dupPolicy:  h.dupPolicy,
+		terminate:  h.terminate,
+		ctxAttrs:   h.ctxAttrs,
+		stack:      h.stack,
+		keys:       h.keys,
+		fromLogger: h.fromLogger,
 	}
 }
 
@@ -79,8 +179,17 @@ func (h *Handler) clone() *Handler {
 // or the method does not take a context.
 // The context is passed so Enabled can use its values
 // to make a decision.
-func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
-	return h.level.Level() >= level
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler,
+// the level of the logrus logger still applies.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if !h.base.Logger.IsLevelEnabled(h.levelMap.Map(level)) {
+		return false
+	}
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		return level >= override
+	}
+	return level >= h.level.Level()
 }
 
 // Handle handles the Record.
@@ -100,91 +209,153 @@ func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
 //   - If a group's key is empty, inline the group's Attrs.
 //   - If a group has no Attrs (even if it has a non-empty key),
 //     ignore it.
-func (h *Handler) Handle(_ context.Context, r slog.Record) error {
-	recordAttrs := []slog.Attr{}
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
 	r.Attrs(func(a slog.Attr) bool {
-		if a.Key == "" {
-			return true
-		}
 		recordAttrs = append(recordAttrs, a)
 		return true
 	})
 
-	var fields logrus.Fields
-	var err error
+	attrs, err := h.scope.Normalize(recordAttrs, h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
 
-	if h.isJSON {
-		fields, err = attrs2JSONLogrusField(h.attrGroup.WithAttrs(recordAttrs).Attrs())
-	} else {
-		fields, err = attrs2TextLogrusField(h.attrGroup.WithAttrs(recordAttrs).Attrs())
+	// a key repeated across WithAttrs and the record needs all the attrs to apply the policy,
+	// they are then converted on the entry without the fields of WithAttrs
+	entry, conflict := h.logr, h.scope.Conflict(attrs)
+	if conflict {
+		entry = h.base
+		if attrs, err = h.scope.Attrs(attrs, h.dupPolicy); err != nil {
+			return err
+		}
 	}
 
-	if err != nil {
-		return err
+	var fields logrus.Fields
+	switch {
+	case h.isJSON && conflict:
+		fields = attrs2JSONLogrusField(attrs)
+	case h.isJSON:
+		fields = h.nest(attrs2JSONLogrusField(attrs))
+	case conflict:
+		fields = attrs2TextLogrusField("", attrs)
+	default:
+		fields = attrs2TextLogrusField(h.prefix, attrs)
 	}
 
-	if !r.Time.IsZero() {
-		fields[timeKey] = r.Time
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+
+	switch {
+	case b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime && h.fromLogger:
+		entry = entry.WithTime(b.Time.Value.Time())
+	case b.Time.Key == slog.TimeKey:
+		fields[h.keys.Time] = b.Time.Value.Any()
+	case b.Time.Key != "":
+		fields[b.Time.Key] = b.Time.Value.Any()
+	}
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		switch {
+		case h.keys.Source != "" && h.isJSON:
+			fields[h.keys.Source] = src
+		case h.keys.Source != "":
+			fields[h.keys.Source+".function"] = src.Function
+			fields[h.keys.Source+".file"] = src.File
+			fields[h.keys.Source+".line"] = src.Line
+		default:
+			fields[h.keys.File] = fmt.Sprintf("%s:%d", src.File, src.Line)
+			fields[h.keys.Func] = src.Function
+		}
+	} else if b.Source.Key != "" {
+		fields[b.Source.Key] = b.Source.Value.Any()
 	}
 
-	if h.addSource && r.PC != 0 {
-		fs := runtime.CallersFrames([]uintptr{r.PC})
-		f, _ := fs.Next()
-		fields[fileKey] = fmt.Sprintf("%s:%d", f.File, f.Line)
-		fields[funcKey] = f.Function
+	if h.stack != nil && b.Level >= h.stack.Level() {
+		fields[stackKey] = helper.Stack(r.PC, 1)
 	}
 
-	h.logr.WithFields(fields).Log(level2LogrusLevel(r.Level), r.Message)
+	h.log(entry.WithFields(fields), h.levelMap.Map(b.Level), b.Message)
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
 	return nil
 }
 
-func attrs2TextLogrusField(attrs []slog.Attr) (m logrus.Fields, err error) {
-	m = logrus.Fields{}
-	var rec func(prefix string, attrs []slog.Attr) error
-	rec = func(prefix string, attrs []slog.Attr) error {
-		for _, attr := range attrs {
-			if _, ok := m[prefix+attr.Key]; ok {
-				return fmt.Errorf("dup key: %s", attr.Key)
+// log writes the entry, logrus panics after writing an entry at PanicLevel,
+// that panic is recovered so that Terminate decides what happens, by the slog level of the record
+func (h *Handler) log(entry *logrus.Entry, level logrus.Level, msg string) {
+	defer func() {
+		if level > logrus.PanicLevel {
+			return
+		}
+		if r := recover(); r != nil {
+			if _, ok := r.(*logrus.Entry); !ok {
+				panic(r)
 			}
+		}
+	}()
+	entry.Log(level, msg)
+}
 
-			if attr.Value.Kind() == slog.KindGroup {
-				err = rec(prefix+attr.Key+".", attr.Value.Resolve().Group())
-				if err != nil {
-					return err
-				}
-			} else {
-				m[prefix+attr.Key] = attr.Value.Any()
-			}
+// nest puts the fields of a record into the open groups, with the fields of WithAttrs,
+// a group without fields is left out
+func (h *Handler) nest(fields logrus.Fields) logrus.Fields {
+	groups := h.scope.Groups()
+	for i := len(h.fields) - 1; i >= 0; i-- {
+		if len(h.fields[i])+len(fields) == 0 {
+			continue
+		}
+		inner := make(logrus.Fields, len(h.fields[i])+len(fields))
+		for k, v := range h.fields[i] {
+			inner[k] = v
+		}
+		for k, v := range fields {
+			inner[k] = v
 		}
-		return nil
+		fields = logrus.Fields{groups[i]: inner}
 	}
-	return m, rec("", attrs)
+	return fields
 }
 
-func attrs2JSONLogrusField(attrs []slog.Attr) (m logrus.Fields, err error) {
-	var rec func(m logrus.Fields, attrs []slog.Attr) error
-	rec = func(m logrus.Fields, attrs []slog.Attr) error {
-		for _, attr := range attrs {
-			if _, ok := m[attr.Key]; ok {
-				return fmt.Errorf("dup key: %s", attr.Key)
-			}
+// attrs2TextLogrusField converts normalized attrs, the keys are prefixed by the keys of their groups
+func attrs2TextLogrusField(prefix string, attrs []slog.Attr) logrus.Fields {
+	m := logrus.Fields{}
+	for _, attr := range helper.Flatten(nil, prefix, attrs) {
+		m[attr.Key] = attr.Value.Any()
+	}
+	return m
+}
 
-			if attr.Value.Kind() == slog.KindGroup {
-				inner := logrus.Fields{}
-				err = rec(inner, attr.Value.Resolve().Group())
-				if err != nil {
-					return err
-				}
-				m[attr.Key] = inner
-			} else {
-				m[attr.Key] = attr.Value.Any()
-			}
+// attrs2JSONLogrusField converts normalized attrs, groups become nested logrus.Fields
+func attrs2JSONLogrusField(attrs []slog.Attr) logrus.Fields {
+	m := make(logrus.Fields, len(attrs))
+	for _, attr := range attrs {
+		if attr.Value.Kind() == slog.KindGroup {
+			m[attr.Key] = attrs2JSONLogrusField(attr.Value.Group())
+		} else {
+			m[attr.Key] = attr.Value.Any()
 		}
-		return nil
 	}
-	m = logrus.Fields{}
-	err = rec(m, attrs)
-	return m, err
+	return m
+}
+
+// Sync flushes the output of the logger, see logger.SyncWriter.
+func (h *Handler) Sync() error {
+	return logger.SyncWriter(h.base.Logger.Out)
+}
+
+// Close syncs the output of the logger and closes the writer of NewHandler, see logger.CloseWriter,
+// the output of a logger supplied by the caller is only synced, as it is owned by the caller.
+func (h *Handler) Close() error {
+	if h.fromLogger {
+		return h.Sync()
+	}
+	return errors.Join(h.Sync(), logger.CloseWriter(h.base.Logger.Out))
 }
 
 // WithAttrs returns a new Handler whose attributes consist of
@@ -192,7 +363,25 @@ func attrs2JSONLogrusField(attrs []slog.Attr) (m logrus.Fields, err error) {
 // The Handler owns the slice: it may retain, modify or discard it.
 func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	cp := h.clone()
-	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	scope, attrs := h.scope.WithAttrs(attrs, h.replace)
+	cp.scope = scope
+	switch {
+	case len(attrs) == 0:
+	case h.isJSON && len(h.fields) > 0:
+		// the fields are kept until a record closes the group
+		last := len(h.fields) - 1
+		group := attrs2JSONLogrusField(attrs)
+		for k, v := range h.fields[last] {
+			if _, ok := group[k]; !ok {
+				group[k] = v
+			}
+		}
+		cp.fields = append(h.fields[:last:last], group)
+	case h.isJSON:
+		cp.logr = h.logr.WithFields(attrs2JSONLogrusField(attrs))
+	default:
+		cp.logr = h.logr.WithFields(attrs2TextLogrusField(h.prefix, attrs))
+	}
 	return cp
 }
 
@@ -216,7 +405,15 @@ func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 //
 // If the name is empty, WithGroup returns the receiver.
 func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
 	cp := h.clone()
-	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	cp.scope = h.scope.WithGroup(name)
+	if h.isJSON {
+		cp.fields = append(h.fields[:len(h.fields):len(h.fields)], nil)
+	} else {
+		cp.prefix = h.prefix + name + "."
+	}
 	return cp
 }
@@ -3,12 +3,22 @@ package logrus
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
 )
 
 /*
@@ -106,6 +116,47 @@ func TestDefaultHandle(t *testing.T) {
 	}
 }
 
+func TestHandlerFromEntry(t *testing.T) {
+	now := time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)
+	buf := &bytes.Buffer{}
+
+	logr := logrus.New()
+	logr.Out = buf
+	logr.Formatter = &logrus.JSONFormatter{TimestampFormat: time.RFC3339}
+	logr.Level = logrus.InfoLevel
+
+	logr.ReportCaller = true
+	hook := test.NewLocal(logr)
+
+	h := NewHandlerFromEntry(logr.WithField("service", "app"), &HandlerOptions{Level: slog.LevelDebug}).
+		WithGroup("s")
+	// the logger is shared, its level and caller reporting are left alone
+	if logr.Level != logrus.InfoLevel || !logr.ReportCaller {
+		t.Errorf("the logger was modified: level %s, ReportCaller %t", logr.Level, logr.ReportCaller)
+	}
+	if h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("want debug disabled by the level of the logger")
+	}
+	logr.ReportCaller = false
+
+	r := slog.NewRecord(now, slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"level":"info","msg":"message","s.a":1,"service":"app","time":"2023-10-16T12:00:00Z"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+	if n := len(hook.AllEntries()); n != 1 {
+		t.Fatalf("want the hook to fire once, got %d", n)
+	}
+	if got := hook.LastEntry().Time; !got.Equal(now) {
+		t.Errorf("want time %v, got %v", now, got)
+	}
+}
+
 func TestConcurrentWrites(t *testing.T) {
 	ctx := context.Background()
 	count := 1000
@@ -151,3 +202,333 @@ func TestConcurrentWrites(t *testing.T) {
 		})
 	}
 }
+
+func TestConformance(t *testing.T) {
+	keys := map[string]string{timeKey: slog.TimeKey, fileKey: slog.SourceKey}
+	for _, isJSON := range []bool{false, true} {
+		isJSON := isJSON
+		parse := conformance.ParseLogfmt
+		if isJSON {
+			parse = conformance.ParseJSON
+		}
+		t.Run(fmt.Sprintf("json=%t", isJSON), func(t *testing.T) {
+			conformance.Run(t, conformance.Backend{
+				NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+					return NewHandler(w, &HandlerOptions{AddSource: addSource, JSONFormatter: isJSON})
+				},
+				Parse: func(out []byte) ([]map[string]any, error) {
+					ms, err := parse(out)
+					for i := range ms {
+						ms[i] = conformance.Rename(ms[i], keys)
+						if !isJSON {
+							ms[i] = conformance.Unflatten(ms[i], ".")
+						}
+					}
+					return ms, err
+				},
+			})
+		})
+	}
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[logrus.Level]
+		level    slog.Level
+		want     string
+	}{
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: "level=info msg=message\n"},
+		{name: "above fatal", level: logger.LevelFatal + 4, want: "level=fatal msg=message\n"},
+		{name: "below trace", level: logger.LevelTrace - 4, want: "level=trace msg=message\n"},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[logrus.Level]{{Min: slog.LevelDebug, Level: logrus.DebugLevel}, {Min: slog.LevelInfo + 2, Level: logrus.WarnLevel}},
+			level:    slog.LevelInfo + 2,
+			want:     "level=warning msg=message\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(buf, &HandlerOptions{Level: logger.LevelTrace - 4, LevelMap: test.levelMap, Terminate: logger.LogOnly})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplaceAttr(t *testing.T) {
+	ctx := context.Background()
+	replace := func(groups []string, a slog.Attr) slog.Attr {
+		switch {
+		case a.Key == slog.TimeKey && len(groups) == 0:
+			return slog.Int64("ts", a.Value.Time().Unix())
+		case a.Key == slog.LevelKey && len(groups) == 0:
+			return slog.Any(a.Key, slog.LevelWarn)
+		case a.Key == "secret":
+			return slog.Attr{}
+		case a.Key == "b":
+			return slog.String(strings.Join(append(groups, a.Key), "_"), a.Value.String())
+		}
+		return a
+	}
+
+	for _, test := range []struct {
+		name   string
+		isJSON bool
+		want   string
+	}{
+		{name: "text", want: "level=warning msg=message s.g.s_g_b=two ts=1697457600\n"},
+		{name: "json", isJSON: true, want: `{"level":"warning","msg":"message","s":{"g":{"s_g_b":"two"}},"ts":1697457600}` + "\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(buf, &HandlerOptions{JSONFormatter: test.isJSON, ReplaceAttr: replace}).
+				WithAttrs([]slog.Attr{slog.String("secret", "hidden")}).
+				WithGroup("s")
+			r := slog.NewRecord(time.Unix(1697457600, 0), slog.LevelInfo, "message", 0)
+			r.AddAttrs(slog.Group("", slog.Group("g", slog.String("b", "two"), slog.String("secret", "hidden"))))
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{AddSource: true, JSONFormatter: true, Keys: logger.Keys{
+		Time:    "@timestamp",
+		Level:   "severity",
+		Message: "message",
+		Source:  "caller",
+	}})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ms[0]
+	for key, want := range map[string]any{"@timestamp": "2023-10-16T12:00:00Z", "severity": "info", "message": "hello"} {
+		if got[key] != want {
+			t.Errorf("%q: got %v, want %v", key, got[key], want)
+		}
+	}
+	src, _ := got["caller"].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/logrus.TestKeys"; src["function"] != want {
+		t.Errorf("caller: got %v, want function %s", got["caller"], want)
+	}
+}
+
+func TestDuplicateKeyPolicy(t *testing.T) {
+	ctx := context.Background()
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.Int("b", 1)), slog.Int("a", 2))
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Group("g", slog.Int("b", 0))})
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "level=info msg=message a=2 g.b=1\n"; got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+
+	buf.Reset()
+	h = NewHandler(buf, &HandlerOptions{DuplicateKeyPolicy: logger.DuplicateKeyError})
+	if err := h.Handle(ctx, r); err == nil || err.Error() != "dup key: a" {
+		t.Errorf("want a dup key error, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("want no output, got %s", buf.String())
+	}
+}
+
+func TestWithAttrsJSON(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2").
+					WithGroup("s3")
+			},
+			attrs: []slog.Attr{slog.Group("s4"), slog.Int("a", 1)},
+			want:  `{"level":"info","msg":"message","p1":1,"s1":{"p2":2,"s2":{"s3":{"a":1}}}}`,
+		},
+		{
+			name: "repeated group",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Group("g", slog.Int("b", 0))})
+			},
+			attrs: []slog.Attr{slog.Group("g", slog.Int("b", 1), slog.Int("c", 2))},
+			want:  `{"g":{"b":1,"c":2},"level":"info","msg":"message"}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := test.with(NewHandler(buf, &HandlerOptions{JSONFormatter: true}))
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want+"\n" {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+// syncBuffer records the calls of Sync and Close
+type syncBuffer struct {
+	bytes.Buffer
+	synced, closed int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced++
+	return nil
+}
+
+func (b *syncBuffer) Close() error {
+	b.closed++
+	return nil
+}
+
+func TestSyncClose(t *testing.T) {
+	buf := &syncBuffer{}
+	h := NewHandler(buf, &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g")
+	if err := logger.Sync(h); err != nil {
+		t.Fatal(err)
+	}
+	if buf.synced != 1 || buf.closed != 0 {
+		t.Errorf("after Sync: synced %d times, closed %d times", buf.synced, buf.closed)
+	}
+	if err := logger.Close(h); err != nil {
+		t.Fatal(err)
+	}
+	if buf.synced != 2 || buf.closed != 1 {
+		t.Errorf("after Close: synced %d times, closed %d times", buf.synced, buf.closed)
+	}
+}
+
+func TestCloseStderr(t *testing.T) {
+	h := NewHandler(os.Stderr, &HandlerOptions{})
+	if err := logger.Close(h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stderr.Stat(); err != nil {
+		t.Errorf("want stderr left open, got %v", err)
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	ctx := context.Background()
+	var codes []int
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{ExitFunc: func(code int) { codes = append(codes, code) }})
+
+	func() {
+		defer func() {
+			if r := recover(); r != "message" {
+				t.Errorf("want a panic with the message, got %v", r)
+			}
+		}()
+		_ = h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelPanic, "message", 0))
+	}()
+	if err := h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelFatal, "message", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(codes) != "[1]" {
+		t.Errorf("want exit(1) once, got %v", codes)
+	}
+	if n := strings.Count(buf.String(), "message"); n != 2 {
+		t.Errorf("want both records written, got %s", buf.String())
+	}
+
+	var levels []slog.Level
+	h = NewHandler(io.Discard, &HandlerOptions{Terminate: func(level slog.Level, msg string) { levels = append(levels, level) }})
+	for _, level := range []slog.Level{slog.LevelError, logger.LevelPanic, logger.LevelFatal + 4} {
+		if err := h.Handle(ctx, slog.NewRecord(time.Time{}, level, "message", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := fmt.Sprint([]slog.Level{logger.LevelPanic, logger.LevelFatal + 4}); fmt.Sprint(levels) != want {
+		t.Errorf("want Terminate called with %s, got %v", want, levels)
+	}
+}
+
+type requestIDKey struct{}
+
+func TestContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{ContextExtractors: []logger.ContextExtractor{
+		func(ctx context.Context) []slog.Attr {
+			if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+				return []slog.Attr{slog.String("request_id", id)}
+			}
+			return nil
+		},
+	}})
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "r1")
+	if h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want debug disabled without a context level")
+	}
+	ctx = logger.ContextWithLevel(ctx, slog.LevelDebug)
+	if !h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want debug enabled by the context level")
+	}
+
+	r := slog.NewRecord(time.Time{}, slog.LevelDebug, "message", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "level=debug msg=message a=1 request_id=r1\n"; got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestStacktrace(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := slog.New(NewHandler(buf, &HandlerOptions{JSONFormatter: true, StacktraceLevel: slog.LevelError}))
+	l.Warn("no stack")
+	l.Error("stack")
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ms[0]["stack"]; ok {
+		t.Errorf("want no stack below the level, got %v", ms[0])
+	}
+	stack, _ := ms[1]["stack"].(string)
+	if want := "github.com/m40Jc001/slog-handler-adapter/logrus.TestStacktrace\n\t"; !strings.HasPrefix(stack, want) {
+		t.Errorf("want the stack to start at the caller, got %q", stack)
+	}
+}
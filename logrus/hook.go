@@ -0,0 +1,159 @@
+package logrus
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+/*
+	implement logrus.Hook and logrus.Formatter
+*/
+
+var _ logrus.Hook = (*SlogHook)(nil)
+var _ logrus.Formatter = (*SlogFormatter)(nil)
+
+type SlogHookOptions struct {
+	// Levels are the logrus levels of the entries that are forwarded, all of them by default.
+	Levels []logrus.Level
+
+	// LevelMap maps logrus levels to slog levels,
+	// by default they are the levels of the root package which the default HandlerOptions.LevelMap maps to them,
+	// such as logger.LevelTrace for logrus.TraceLevel and logger.LevelFatal for logrus.FatalLevel.
+	LevelMap map[logrus.Level]slog.Level
+
+	// Terminate passes the records at or above logger.LevelPanic at their level, so that the handler terminates as well,
+	// by default they are passed at logger.LevelError, as logrus panics and exits by itself.
+	Terminate bool
+}
+
+// SlogHook forwards the entries of a logrus.Logger to a slog.Handler, in addition to the output of the logger,
+// so that the code which calls logrus directly writes to the same pipeline as slog.
+//
+// Each entry becomes a slog.Record with the time, the level and the message of the entry,
+// and its data fields as attrs sorted by key, a nested logrus.Fields value becomes a group.
+// The context of the entry, if any, is passed to the handler.
+// The caller is the PC of Entry.Caller, which logrus sets when ReportCaller is enabled.
+//
+// logrus panics and exits by itself after a PanicLevel or FatalLevel entry, after the hooks have fired,
+// so the records at or above logger.LevelPanic are passed at logger.LevelError unless SlogHookOptions.Terminate is set,
+// as the handlers of this repository terminate after such a record.
+type SlogHook struct {
+	handler   slog.Handler
+	levels    []logrus.Level
+	levelMap  map[logrus.Level]slog.Level
+	terminate bool
+}
+
+// NewSlogHook returns a hook to add to a logrus.Logger, with AddHook, that forwards its entries to handler.
+func NewSlogHook(handler slog.Handler, options *SlogHookOptions) *SlogHook {
+	levels := options.Levels
+	if len(levels) == 0 {
+		levels = logrus.AllLevels
+	}
+	return &SlogHook{
+		handler:   handler,
+		levels:    levels,
+		levelMap:  options.LevelMap,
+		terminate: options.Terminate,
+	}
+}
+
+// Levels returns the logrus levels of the entries that are forwarded.
+func (h *SlogHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire forwards an entry to the handler when it is enabled at the slog level of the entry,
+// the error of the handler is returned, which logrus reports on stderr.
+func (h *SlogHook) Fire(entry *logrus.Entry) error {
+	return handleEntry(h.handler, h.levelMap, h.terminate, entry)
+}
+
+// SlogFormatter replaces the output of a logrus.Logger by a slog.Handler, see SlogHook for the conversion of the entries.
+// It is both the formatter and the output of the logger, which it discards:
+//
+//	f := logrus.NewSlogFormatter(handler, &logrus.SlogHookOptions{})
+//	l.SetFormatter(f)
+//	l.SetOutput(f)
+//
+// The formatter is called under the lock of the logger, the entries reach the handler one at a time.
+// SlogHookOptions.Levels does not apply, the entries are filtered by the level of the logger and of the handler.
+type SlogFormatter struct {
+	handler   slog.Handler
+	levelMap  map[logrus.Level]slog.Level
+	terminate bool
+}
+
+// NewSlogFormatter returns a formatter that forwards the entries of a logrus.Logger to handler.
+func NewSlogFormatter(handler slog.Handler, options *SlogHookOptions) *SlogFormatter {
+	return &SlogFormatter{
+		handler:   handler,
+		levelMap:  options.LevelMap,
+		terminate: options.Terminate,
+	}
+}
+
+// Format forwards an entry to the handler when it is enabled at the slog level of the entry,
+// and returns no bytes, the error of the handler is returned, which logrus reports on stderr.
+func (f *SlogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return nil, handleEntry(f.handler, f.levelMap, f.terminate, entry)
+}
+
+// Write discards p, the entries are written by the handler.
+func (f *SlogFormatter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// handleEntry converts an entry to a slog.Record and passes it to handler if it is enabled at the level of the record,
+// the levels at or above logger.LevelPanic are lowered to logger.LevelError unless terminate is true
+func handleEntry(handler slog.Handler, levelMap map[logrus.Level]slog.Level, terminate bool, entry *logrus.Entry) error {
+	level, ok := levelMap[entry.Level]
+	if !ok {
+		level = defaultSlogLevels[entry.Level]
+	}
+	if level >= logger.LevelPanic && !terminate {
+		level = logger.LevelError
+	}
+
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	var pc uintptr
+	if entry.Caller != nil {
+		pc = entry.Caller.PC
+	}
+
+	r := slog.NewRecord(entry.Time, level, entry.Message, pc)
+	r.AddAttrs(fields2Attrs(entry.Data)...)
+	return handler.Handle(ctx, r)
+}
+
+// fields2Attrs converts logrus fields to attrs sorted by key, a nested logrus.Fields value becomes a group,
+// as attrs2JSONLogrusField writes groups
+func fields2Attrs(fields logrus.Fields) []slog.Attr {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		if group, ok := fields[k].(logrus.Fields); ok {
+			attrs = append(attrs, slog.Attr{Key: k, Value: slog.GroupValue(fields2Attrs(group)...)})
+			continue
+		}
+		attrs = append(attrs, slog.Any(k, fields[k]))
+	}
+	return attrs
+}
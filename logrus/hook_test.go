@@ -0,0 +1,177 @@
+package logrus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+// newLogrus returns a logger which writes its own output to out, at all levels
+func newLogrus(out io.Writer) *logrus.Logger {
+	l := logrus.New()
+	l.SetOutput(out)
+	l.SetLevel(logrus.TraceLevel)
+	return l
+}
+
+func TestSlogHook(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &bytes.Buffer{}
+	l := newLogrus(out)
+	l.SetReportCaller(true)
+	l.AddHook(NewSlogHook(slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true, Level: logger.LevelTrace}), &SlogHookOptions{}))
+
+	now := time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)
+	l.WithTime(now).WithFields(logrus.Fields{
+		"b": "two",
+		"a": 1,
+		"g": logrus.Fields{"c": true},
+	}).Trace("message")
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ms[0]
+	for key, want := range map[string]any{
+		slog.TimeKey:    "2023-10-16T12:00:00Z",
+		slog.LevelKey:   "DEBUG-4",
+		slog.MessageKey: "message",
+		"a":             float64(1),
+		"b":             "two",
+	} {
+		if got[key] != want {
+			t.Errorf("%q: got %v, want %v", key, got[key], want)
+		}
+	}
+	if g, _ := got["g"].(map[string]any); g["c"] != true {
+		t.Errorf("want a group for the nested fields, got %v", got["g"])
+	}
+	src, _ := got[slog.SourceKey].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/logrus.TestSlogHook"; src["function"] != want {
+		t.Errorf("source: got %v, want function %s", got[slog.SourceKey], want)
+	}
+	if !strings.Contains(out.String(), "msg=message") {
+		t.Errorf("want the output of the logger kept, got %s", out.String())
+	}
+
+	// the attrs are sorted by key
+	if i, j := strings.Index(buf.String(), `"a":`), strings.Index(buf.String(), `"b":`); i > j {
+		t.Errorf("want the attrs sorted by key, got %s", buf.String())
+	}
+}
+
+func TestSlogHookLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := newLogrus(io.Discard)
+	l.AddHook(NewSlogHook(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}), &SlogHookOptions{
+		Levels:   []logrus.Level{logrus.DebugLevel, logrus.InfoLevel, logrus.ErrorLevel},
+		LevelMap: map[logrus.Level]slog.Level{logrus.ErrorLevel: slog.LevelWarn},
+	}))
+
+	l.Debug("below the level of the handler")
+	l.Info("info")
+	l.Warn("not a level of the hook")
+	l.Error("error")
+
+	got := buf.String()
+	for _, msg := range []string{"below the level of the handler", "not a level of the hook"} {
+		if strings.Contains(got, msg) {
+			t.Errorf("want %q filtered, got %s", msg, got)
+		}
+	}
+	if !strings.Contains(got, "level=INFO msg=info") || !strings.Contains(got, "level=WARN msg=error") {
+		t.Errorf("want info and error forwarded, error as warn, got %s", got)
+	}
+}
+
+func TestSlogHookContext(t *testing.T) {
+	var got context.Context
+	h := &contextHandler{Handler: slog.NewTextHandler(io.Discard, nil), ctx: &got}
+	l := newLogrus(io.Discard)
+	l.AddHook(NewSlogHook(h, &SlogHookOptions{}))
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "r1")
+	l.WithContext(ctx).Info("message")
+	if got == nil || got.Value(requestIDKey{}) != "r1" {
+		t.Errorf("want the context of the entry passed to the handler, got %v", got)
+	}
+}
+
+// contextHandler records the context passed to Handle
+type contextHandler struct {
+	slog.Handler
+	ctx *context.Context
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	*h.ctx = ctx
+	return h.Handler.Handle(ctx, r)
+}
+
+func TestSlogHookPanic(t *testing.T) {
+	var codes []int
+	buf := &bytes.Buffer{}
+	l := newLogrus(io.Discard)
+	l.ExitFunc = func(int) {}
+	l.AddHook(NewSlogHook(NewHandler(buf, &HandlerOptions{ExitFunc: func(code int) { codes = append(codes, code) }}), &SlogHookOptions{}))
+
+	func() {
+		defer func() {
+			if _, ok := recover().(*logrus.Entry); !ok {
+				t.Error("want logrus to panic with the entry")
+			}
+		}()
+		l.Panic("message")
+	}()
+	l.Fatal("message")
+
+	if len(codes) != 0 {
+		t.Errorf("want the handler not to exit, got %v", codes)
+	}
+	if got, want := strings.Count(buf.String(), "level=error msg=message "), 2; got != want {
+		t.Errorf("want the records written at error, got %s", buf.String())
+	}
+
+	buf.Reset()
+	l = newLogrus(io.Discard)
+	l.AddHook(NewSlogHook(NewHandler(buf, &HandlerOptions{Terminate: logger.LogOnly}), &SlogHookOptions{Terminate: true}))
+	func() {
+		defer func() { _ = recover() }()
+		l.Panic("message")
+	}()
+	if got, want := buf.String(), "level=panic msg=message "; !strings.HasPrefix(got, want) {
+		t.Errorf("\ngot  %s\nwant %s...", got, want)
+	}
+}
+
+func TestSlogFormatter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := newLogrus(io.Discard)
+	f := NewSlogFormatter(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}), &SlogHookOptions{})
+	l.SetFormatter(f)
+	l.SetOutput(f)
+
+	l.Debug("below the level of the handler")
+	l.WithField("a", 1).Warn("message")
+
+	if got, want := buf.String(), "level=WARN msg=message a=1\n"; got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
@@ -1,5 +1,20 @@
 package logrus
 
+import (
+	"github.com/sirupsen/logrus"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
 const fileKey string = "file"
 const funcKey string = "func"
 const timeKey string = "timestamp"
+const stackKey string = "stack"
+
+var defaultKeys = logger.Keys{
+	Time:    timeKey,
+	Level:   logrus.FieldKeyLevel,
+	Message: logrus.FieldKeyMsg,
+	File:    fileKey,
+	Func:    funcKey,
+}
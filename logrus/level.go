@@ -8,22 +8,26 @@ import (
 	logger "github.com/m40Jc001/slog-handler-adapter"
 )
 
-func level2LogrusLevel(level slog.Level) logrus.Level {
-	switch level {
-	case logger.LevelTrace:
-		return logrus.TraceLevel
-	case logger.LevelDebug:
-		return logrus.DebugLevel
-	case logger.LevelInfo:
-		return logrus.InfoLevel
-	case logger.LevelWarn:
-		return logrus.WarnLevel
-	case logger.LevelError:
-		return logrus.ErrorLevel
-	case logger.LevelPanic:
-		return logrus.PanicLevel
-	case logger.LevelFatal:
-		return logrus.FatalLevel
-	}
-	return logrus.TraceLevel // TODO: Here, if the above does not map, it is converted to the lowest log level, not sure if there is a better way to do this
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// levels between two constants of the root package are mapped to the lower one.
+var defaultLevelMap = logger.LevelMap[logrus.Level]{
+	{Min: logger.LevelTrace, Level: logrus.TraceLevel},
+	{Min: logger.LevelDebug, Level: logrus.DebugLevel},
+	{Min: logger.LevelInfo, Level: logrus.InfoLevel},
+	{Min: logger.LevelWarn, Level: logrus.WarnLevel},
+	{Min: logger.LevelError, Level: logrus.ErrorLevel},
+	{Min: logger.LevelPanic, Level: logrus.PanicLevel},
+	{Min: logger.LevelFatal, Level: logrus.FatalLevel},
+}
+
+// defaultSlogLevels is the reverse of defaultLevelMap, used by SlogHook and SlogFormatter
+// when SlogHookOptions.LevelMap has no slog level for a logrus level.
+var defaultSlogLevels = map[logrus.Level]slog.Level{
+	logrus.TraceLevel: logger.LevelTrace,
+	logrus.DebugLevel: logger.LevelDebug,
+	logrus.InfoLevel:  logger.LevelInfo,
+	logrus.WarnLevel:  logger.LevelWarn,
+	logrus.ErrorLevel: logger.LevelError,
+	logrus.PanicLevel: logger.LevelPanic,
+	logrus.FatalLevel: logger.LevelFatal,
 }
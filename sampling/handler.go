@@ -0,0 +1,261 @@
+package sampling
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+
+// counterSize is the number of counters of a level, the messages are hashed into them as zap does,
+// so that the memory does not grow with the number of distinct messages.
+const counterSize = 4096
+
+// Budget is the number of records with the same level and message written per tick:
+// the First records, then every Thereafter-th record, the others are dropped.
+// A Thereafter of zero drops every record after the First ones,
+// a negative First writes every record.
+type Budget struct {
+	First      int
+	Thereafter int
+}
+
+var defaultBudget = Budget{First: 100, Thereafter: 100}
+
+type Handler struct {
+	handler slog.Handler
+	sampler *sampler
+}
+
+type HandlerOptions struct {
+	// Tick is the period after which the counters of a level and a message are reset, one second by default.
+	Tick time.Duration
+
+	// Budget applies to every level when Levels is empty, by default the first 100 records
+	// of a tick are written, then every 100th, as with the production config of zap.
+	Budget Budget
+
+	// Levels maps slog levels to their budgets, see logger.LevelMap,
+	// levels below every range have the budget of the lowest range.
+	Levels logger.LevelMap[Budget]
+
+	// Hook is called with every dropped record,
+	// and the number of records with the same level and message dropped in the current tick, including this one.
+	Hook func(ctx context.Context, r slog.Record, dropped uint64)
+}
+
+// sampler is shared by a Handler and the handlers derived from it
+type sampler struct {
+	tick    time.Duration
+	budget  Budget
+	levels  logger.LevelMap[Budget]
+	hook    func(ctx context.Context, r slog.Record, dropped uint64)
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	counts map[slog.Level]*[counterSize]counter
+}
+
+// counter counts the records of a tick, resetAt is the end of the tick in unix nanoseconds
+type counter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+// NewHandler wraps handler with a sampler in the manner of zap, which writes the first records
+// with the same level and message in every tick, then every n-th one, and drops the others,
+// so that a message in a hot path does not flood the output.
+//
+// The tick of a record is given by its time, or by the current time when the time is zero.
+// Records at logger.LevelPanic or above are never dropped, so the wrapped handler still terminates.
+// The counters are shared by the handlers derived from this one by WithAttrs and WithGroup, as in zap.
+//
+// Sync and Close are forwarded to the wrapped handler by logger.Sync and logger.Close through Unwrap.
+func NewHandler(handler slog.Handler, options *HandlerOptions) *Handler {
+	tick := options.Tick
+	if tick <= 0 {
+		tick = time.Second
+	}
+
+	budget := options.Budget
+	if budget == (Budget{}) {
+		budget = defaultBudget
+	}
+
+	return &Handler{
+		handler: handler,
+		sampler: &sampler{
+			tick:   tick,
+			budget: budget,
+			levels: options.Levels,
+			hook:   options.Hook,
+			counts: map[slog.Level]*[counterSize]counter{},
+		},
+	}
+}
+
+// incCheckReset increments the counter, or resets it when the tick which ended at resetAt is over,
+// and returns the count of the current tick
+func (c *counter) incCheckReset(t time.Time, tick time.Duration) uint64 {
+	tn := t.UnixNano()
+	resetAt := c.resetAt.Load()
+	if resetAt > tn {
+		return c.count.Add(1)
+	}
+
+	c.count.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAt, tn+tick.Nanoseconds()) {
+		// another goroutine reset the counter first
+		return c.count.Add(1)
+	}
+	return 1
+}
+
+// counter returns the counter of the level and the message
+func (s *sampler) counter(level slog.Level, msg string) *counter {
+	s.mu.RLock()
+	counts, ok := s.counts[level]
+	s.mu.RUnlock()
+	if !ok {
+		s.mu.Lock()
+		if counts, ok = s.counts[level]; !ok {
+			counts = &[counterSize]counter{}
+			s.counts[level] = counts
+		}
+		s.mu.Unlock()
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(msg))
+	return &counts[hash.Sum32()%counterSize]
+}
+
+// sample reports whether r is written, and calls the hook when it is dropped
+func (s *sampler) sample(ctx context.Context, r slog.Record) bool {
+	if r.Level >= logger.LevelPanic {
+		return true
+	}
+
+	budget := s.budget
+	if len(s.levels) > 0 {
+		budget = s.levels.Map(r.Level)
+	}
+	if budget.First < 0 {
+		return true
+	}
+
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	n := s.counter(r.Level, r.Message).incCheckReset(t, s.tick)
+
+	first := uint64(budget.First)
+	if n <= first {
+		return true
+	}
+	if budget.Thereafter > 0 && (n-first)%uint64(budget.Thereafter) == 0 {
+		return true
+	}
+
+	s.dropped.Add(1)
+	if s.hook != nil {
+		dropped := n - first
+		if budget.Thereafter > 0 {
+			dropped -= (n - first) / uint64(budget.Thereafter)
+		}
+		s.hook(ctx, r, dropped)
+	}
+	return false
+}
+
+// Dropped returns the number of records dropped since NewHandler,
+// it is shared by the handlers derived from this one.
+func (h *Handler) Dropped() uint64 {
+	return h.sampler.dropped.Load()
+}
+
+// Unwrap returns the wrapped handler.
+func (h *Handler) Unwrap() slog.Handler {
+	return h.handler
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// It is the Enabled of the wrapped handler, the records are sampled by Handle.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// A record dropped by the sampler is not an error, Handle returns nil.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.sampler.sample(ctx, r) {
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{
+		handler: h.handler.WithAttrs(attrs),
+		sampler: h.sampler,
+	}
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{
+		handler: h.handler.WithGroup(name),
+		sampler: h.sampler,
+	}
+}
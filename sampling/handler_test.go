@@ -0,0 +1,240 @@
+package sampling
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+	logrushandler "github.com/m40Jc001/slog-handler-adapter/logrus"
+	zaphandler "github.com/m40Jc001/slog-handler-adapter/zap"
+)
+
+// backends are the handlers the sampler is tested in front of
+var backends = []struct {
+	name       string
+	newHandler func(w io.Writer) slog.Handler
+}{
+	{
+		name: "logrus",
+		newHandler: func(w io.Writer) slog.Handler {
+			return logrushandler.NewHandler(w, &logrushandler.HandlerOptions{Level: logger.LevelTrace, Terminate: logger.LogOnly})
+		},
+	},
+	{
+		name: "zap",
+		newHandler: func(w io.Writer) slog.Handler {
+			return zaphandler.NewHandler(w, &zaphandler.HandlerOptions{Level: logger.LevelTrace, Terminate: logger.LogOnly})
+		},
+	},
+}
+
+var t0 = time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// logN handles n records with the level and the message, at the time t
+func logN(h slog.Handler, n int, level slog.Level, msg string, t time.Time) {
+	for i := 0; i < n; i++ {
+		_ = h.Handle(context.Background(), slog.NewRecord(t, level, msg, 0))
+	}
+}
+
+func TestSample(t *testing.T) {
+	for _, backend := range backends {
+		for _, test := range []struct {
+			name   string
+			budget Budget
+			n      int
+			want   int
+		}{
+			{name: "default", n: 1000, want: 109},
+			{name: "first only", budget: Budget{First: 3}, n: 10, want: 3},
+			{name: "first and thereafter", budget: Budget{First: 2, Thereafter: 3}, n: 10, want: 4},
+			{name: "thereafter only", budget: Budget{Thereafter: 5}, n: 10, want: 2},
+			{name: "unlimited", budget: Budget{First: -1}, n: 10, want: 10},
+		} {
+			t.Run(backend.name+"/"+test.name, func(t *testing.T) {
+				buf := &bytes.Buffer{}
+				h := NewHandler(backend.newHandler(buf), &HandlerOptions{Budget: test.budget})
+				logN(h, test.n, slog.LevelInfo, "message", t0)
+
+				if got := strings.Count(buf.String(), "message"); got != test.want {
+					t.Errorf("wrote %d records, want %d", got, test.want)
+				}
+				if got := h.Dropped(); got != uint64(test.n-test.want) {
+					t.Errorf("dropped %d records, want %d", got, test.n-test.want)
+				}
+			})
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(backend.newHandler(buf), &HandlerOptions{Budget: Budget{First: 2}})
+			var l slog.Handler = h
+			logN(l, 5, slog.LevelInfo, "a", t0)
+			logN(l, 5, slog.LevelInfo, "b", t0)
+			logN(l, 5, slog.LevelWarn, "a", t0)
+			// the derived handlers share the counters
+			l = l.WithAttrs([]slog.Attr{slog.Int("x", 1)}).WithGroup("g")
+			logN(l, 5, slog.LevelInfo, "a", t0)
+
+			if got := h.Dropped(); got != 14 {
+				t.Errorf("dropped %d records, want 14", got)
+			}
+			if got := strings.Count(buf.String(), "\n"); got != 6 {
+				t.Errorf("wrote %d records, want 6\n%s", got, buf.String())
+			}
+		})
+	}
+}
+
+func TestTick(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(backends[0].newHandler(buf), &HandlerOptions{Tick: time.Minute, Budget: Budget{First: 1}})
+
+	logN(h, 3, slog.LevelInfo, "message", t0)
+	logN(h, 3, slog.LevelInfo, "message", t0.Add(59*time.Second))
+	logN(h, 3, slog.LevelInfo, "message", t0.Add(time.Minute))
+	logN(h, 3, slog.LevelInfo, "message", t0.Add(2*time.Minute+time.Second))
+
+	if got := strings.Count(buf.String(), "\n"); got != 3 {
+		t.Errorf("wrote %d records, want one per tick\n%s", got, buf.String())
+	}
+}
+
+func TestLevels(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(backend.newHandler(buf), &HandlerOptions{
+				Levels: logger.LevelMap[Budget]{
+					{Min: slog.LevelDebug, Level: Budget{First: 1}},
+					{Min: slog.LevelInfo, Level: Budget{First: 2}},
+					{Min: slog.LevelError, Level: Budget{First: -1}},
+				},
+			})
+			logN(h, 5, logger.LevelTrace, "m-trace", t0)
+			logN(h, 5, slog.LevelInfo, "m-info", t0)
+			logN(h, 5, slog.LevelWarn, "m-warn", t0)
+			logN(h, 5, slog.LevelError, "m-error", t0)
+			logN(h, 5, logger.LevelPanic, "m-panic", t0)
+			logN(h, 5, logger.LevelFatal, "m-fatal", t0)
+
+			out := buf.String()
+			for msg, want := range map[string]int{"m-trace": 1, "m-info": 2, "m-warn": 2, "m-error": 5, "m-panic": 5, "m-fatal": 5} {
+				if got := strings.Count(out, msg); got != want {
+					t.Errorf("wrote %d records of %s, want %d", got, msg, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHook(t *testing.T) {
+	type drop struct {
+		msg     string
+		dropped uint64
+	}
+	var got []drop
+	h := NewHandler(slog.NewTextHandler(io.Discard, nil), &HandlerOptions{
+		Budget: Budget{First: 1, Thereafter: 2},
+		Hook: func(ctx context.Context, r slog.Record, dropped uint64) {
+			got = append(got, drop{r.Message, dropped})
+		},
+	})
+	logN(h, 6, slog.LevelInfo, "a", t0)
+	logN(h, 2, slog.LevelInfo, "b", t0)
+
+	// a: 1 written, 2 dropped, 3 written, 4 dropped, 5 written, 6 dropped
+	want := []drop{{"a", 1}, {"a", 2}, {"a", 3}, {"b", 1}}
+	if len(got) != len(want) {
+		t.Fatalf("\ngot  %v\nwant %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("\ngot  %v\nwant %v", got, want)
+		}
+	}
+}
+
+// syncBuffer records the calls of Sync and Close
+type syncBuffer struct {
+	bytes.Buffer
+	synced, closed int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced++
+	return nil
+}
+
+func (b *syncBuffer) Close() error {
+	b.closed++
+	return nil
+}
+
+func TestSyncClose(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			buf := &syncBuffer{}
+			h := NewHandler(backend.newHandler(buf), &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g")
+			if err := logger.Sync(h); err != nil {
+				t.Fatal(err)
+			}
+			if buf.synced != 1 || buf.closed != 0 {
+				t.Errorf("after Sync: synced %d times, closed %d times", buf.synced, buf.closed)
+			}
+			if err := logger.Close(h); err != nil {
+				t.Fatal(err)
+			}
+			if buf.synced != 2 || buf.closed != 1 {
+				t.Errorf("after Close: synced %d times, closed %d times", buf.synced, buf.closed)
+			}
+		})
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(backend.newHandler(buf), &HandlerOptions{Budget: Budget{First: 10, Thereafter: 10}})
+			// the first record starts the tick, so that the goroutines only increment the counter
+			logN(h, 1, slog.LevelInfo, "message", t0)
+			wg := sync.WaitGroup{}
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					logN(h, 100, slog.LevelInfo, "message", t0)
+				}()
+			}
+			wg.Wait()
+
+			if got := strings.Count(buf.String(), "message"); got != 89 {
+				t.Errorf("wrote %d records, want 89", got)
+			}
+			if got := h.Dropped(); got != 712 {
+				t.Errorf("dropped %d records, want 712", got)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Backend{
+		NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+			return NewHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{AddSource: addSource}), &HandlerOptions{})
+		},
+		Parse: conformance.ParseJSON,
+	})
+}
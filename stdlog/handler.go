@@ -0,0 +1,306 @@
+package stdlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"runtime"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/helper"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
+
+type Handler struct {
+	logr       *log.Logger
+	addSource  bool
+	isJSON     bool
+	level      *slog.LevelVar
+	attrGroup  *helper.AttrGroup
+	levelMap   logger.LevelMap[string]
+	replace    func(groups []string, a slog.Attr) slog.Attr
+	keys       logger.Keys
+	dupPolicy  logger.DuplicateKeyPolicy
+	terminate  logger.TerminateFunc
+	ctxAttrs   []logger.ContextExtractor
+	stack      slog.Leveler
+	fromLogger bool
+}
+
+type HandlerOptions struct {
+	AddSource       bool
+	JSONFormatter   bool
+	Level           slog.Level
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps slog levels to the names of the levels in the output,
+	// by default the levels between two constants of the root package are mapped to the lower one,
+	// named "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "PANIC" and "FATAL".
+	LevelMap logger.LevelMap[string]
+
+	// ReplaceAttr follows helper.Builtins, a time which is replaced by another kind of value or renamed is written as an attr.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the built-in attributes,
+	// the defaults are the keys of log/slog, and "file" and "func" for the source.
+	// The text output writes the level and the message without keys,
+	// and the time only when the flags of the logger leave it out of the header.
+	Keys logger.Keys
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+
+	// Terminate panics or exits by default, as log.Panic and log.Fatal do.
+	Terminate logger.TerminateFunc
+
+	ExitFunc func(code int)
+}
+
+// NewHandler writes to a new log.Logger with the flags log.LstdFlags, see NewHandlerFromLogger.
+func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
+	h := NewHandlerFromLogger(log.New(writer, "", log.LstdFlags), options)
+	h.fromLogger = false
+	return h
+}
+
+// NewHandlerFromLogger writes through an existing *log.Logger, with its prefix, flags and output,
+// so that SetPrefix, SetFlags and SetOutput still take effect.
+//
+// Every record is a single call of log.Logger.Output, under the lock of the logger,
+// so the records do not interleave with the other writes of the logger, such as Print.
+// Output writes the header of the flags, followed by the level, the message and the attrs as logfmt,
+// or by a JSON object with the level, the message and the attrs when JSONFormatter is true.
+//
+// The header has the time of the write, not the time of the record.
+// When the flags leave out Ldate, Ltime and Lmicroseconds, the time of the record is written with the attrs instead.
+// With Lshortfile or Llongfile, the file of the header is the caller at Record.PC when it is on the stack of Handle,
+// otherwise, such as for a record handled on another goroutine, it is the caller of Handle.
+func NewHandlerFromLogger(logr *log.Logger, options *HandlerOptions) *Handler {
+	levelar := &slog.LevelVar{}
+	levelar.Set(options.Level)
+
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
+	return &Handler{
+		logr:       logr,
+		addSource:  options.AddSource,
+		level:      levelar,
+		attrGroup:  &helper.AttrGroup{},
+		levelMap:   levelMap,
+		replace:    options.ReplaceAttr,
+		dupPolicy:  options.DuplicateKeyPolicy,
+		terminate:  terminate,
+		ctxAttrs:   options.ContextExtractors,
+		stack:      options.StacktraceLevel,
+		keys:       options.Keys.WithDefaults(defaultKeys),
+		isJSON:     options.JSONFormatter,
+		fromLogger: true,
+	}
+}
+
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		logr:       h.logr,
+		addSource:  h.addSource,
+		level:      h.level,
+		isJSON:     h.isJSON,
+		attrGroup:  h.attrGroup,
+		levelMap:   h.levelMap,
+		replace:    h.replace,
+		dupPolicy:  h.dupPolicy,
+		terminate:  h.terminate,
+		ctxAttrs:   h.ctxAttrs,
+		stack:      h.stack,
+		keys:       h.keys,
+		fromLogger: h.fromLogger,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		return level >= override
+	}
+	return level >= h.level.Level()
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle methods that produce output should observe the following rules:
+//   - If r.Time is the zero time, ignore the time.
+//   - If r.PC is zero, ignore it.
+//   - Attr's values should be resolved.
+//   - If an Attr's key and value are both the zero value, ignore the Attr.
+//     This can be tested with attr.Equal(Attr{}).
+//   - If a group's key is empty, inline the group's Attrs.
+//   - If a group has no Attrs (even if it has a non-empty key),
+//     ignore it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+
+	attrs, err := helper.Normalize(h.attrGroup.WithAttrs(recordAttrs).Attrs(), h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
+
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+
+	switch {
+	case b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime:
+		if h.logr.Flags()&(log.Ldate|log.Ltime|log.Lmicroseconds) == 0 {
+			attrs = append([]slog.Attr{slog.Time(h.keys.Time, b.Time.Value.Time())}, attrs...)
+		}
+	case b.Time.Key == slog.TimeKey:
+		attrs = append([]slog.Attr{slog.Any(h.keys.Time, b.Time.Value)}, attrs...)
+	case b.Time.Key != "":
+		attrs = append([]slog.Attr{b.Time}, attrs...)
+	}
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		if h.keys.Source != "" {
+			attrs = append(attrs, slog.Group(h.keys.Source,
+				slog.String("function", src.Function),
+				slog.String("file", src.File),
+				slog.Int("line", src.Line)))
+		} else {
+			attrs = append(attrs,
+				slog.String(h.keys.File, fmt.Sprintf("%s:%d", src.File, src.Line)),
+				slog.String(h.keys.Func, src.Function))
+		}
+	} else if b.Source.Key != "" {
+		attrs = append(attrs, b.Source)
+	}
+
+	if h.stack != nil && b.Level >= h.stack.Level() {
+		attrs = append(attrs, slog.String(stackKey, helper.Stack(r.PC, 1)))
+	}
+
+	buf := &bytes.Buffer{}
+	level := h.levelMap.Map(b.Level)
+	if h.isJSON {
+		buf.WriteByte('{')
+		helper.WriteJSON(buf, []slog.Attr{slog.String(h.keys.Level, level), slog.String(h.keys.Message, b.Message)}, true)
+		helper.WriteJSON(buf, attrs, false)
+		buf.WriteByte('}')
+	} else {
+		buf.WriteString(level)
+		buf.WriteByte(' ')
+		buf.WriteString(b.Message)
+		helper.WriteLogfmt(buf, attrs)
+	}
+
+	// Output is called here, as the call depth is counted from Handle
+	err = h.logr.Output(callDepth(r.PC), buf.String())
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
+	return err
+}
+
+// callDepth returns the call depth of log.Logger.Output, called by Handle, for the caller at pc, the PC of the record,
+// or the depth of the caller of Handle if pc is not on the stack
+func callDepth(pc uintptr) int {
+	var pcs [64]uintptr
+	// skip runtime.Callers, callDepth and Handle, whose caller has the depth 2
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for depth := 2; ; depth++ {
+		frame, more := frames.Next()
+		// the PC of a frame is the call instruction, the PC of the record is the return address
+		if frame.PC+1 == pc {
+			return depth
+		}
+		if !more {
+			return 2
+		}
+	}
+}
+
+// Sync flushes the output of the logger, see logger.SyncWriter.
+func (h *Handler) Sync() error {
+	return logger.SyncWriter(h.logr.Writer())
+}
+
+// Close syncs the output of the logger and closes the writer of NewHandler, see logger.CloseWriter,
+// the output of the logger of NewHandlerFromLogger is only synced, as it is owned by the caller.
+func (h *Handler) Close() error {
+	if h.fromLogger {
+		return h.Sync()
+	}
+	return errors.Join(h.Sync(), logger.CloseWriter(h.logr.Writer()))
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	return cp
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	return cp
+}
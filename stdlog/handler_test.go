@@ -0,0 +1,398 @@
+package stdlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+/*
+	This unit test is adapted from go 1.21.1 log/slog/handler_test.go
+	with slight modifications to test the log.Logger handler
+*/
+
+func TestDefaultHandle(t *testing.T) {
+	ctx := context.Background()
+	preAttrs := []slog.Attr{slog.Int("pre", 0)}
+	attrs := []slog.Attr{slog.Int("a", 1), slog.String("b", "two")}
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "no attrs",
+			want: "INFO message",
+		},
+		{
+			name:  "attrs",
+			attrs: attrs,
+			want:  "INFO message a=1 b=two",
+		},
+		{
+			name:  "preformatted",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs) },
+			attrs: attrs,
+			want:  "INFO message pre=0 a=1 b=two",
+		},
+		{
+			name: "groups",
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g",
+					slog.Int("b", 2),
+					slog.Group("h", slog.Int("c", 3)),
+					slog.Int("d", 4)),
+				slog.Int("e", 5),
+			},
+			want: "INFO message a=1 g.b=2 g.h.c=3 g.d=4 e=5",
+		},
+		{
+			name:  "group",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
+			attrs: attrs,
+			want:  "INFO message pre=0 s.a=1 s.b=two",
+		},
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  "INFO message p1=1 s1.p2=2 s1.s2.a=1 s1.s2.b=two",
+		},
+		{
+			name: "two with-groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  "INFO message p1=1 s1.s2.a=1 s1.s2.b=two",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+
+			buf := &bytes.Buffer{}
+
+			var h slog.Handler = newHandler(buf, &HandlerOptions{})
+
+			if test.with != nil {
+				h = test.with(h)
+			}
+
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			got = strings.TrimSuffix(buf.String(), "\n")
+			if got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJSONHandle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := newHandler(buf, &HandlerOptions{JSONFormatter: true}).
+		WithAttrs([]slog.Attr{slog.Int("pre", 0)}).
+		WithGroup("s")
+	r := slog.NewRecord(time.Time{}, slog.LevelWarn, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.String("b", "two")), slog.Any("err", io.EOF))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"level":"WARN","msg":"message","pre":0,"s":{"a":1,"g":{"b":"two"},"err":"EOF"}}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestTextValues(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := newHandler(buf, &HandlerOptions{})
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+	r.AddAttrs(
+		slog.Duration("d", time.Second),
+		slog.Time("t", time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)),
+		slog.Any("list", []int{1, 2}),
+		slog.Any("bytes", []byte("raw")),
+		slog.Any("err", io.EOF),
+		slog.String("quoted", `a "b"`),
+		slog.String("empty", ""),
+	)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `INFO message d=1s t=2023-10-16T12:00:00Z list="[1 2]" bytes=raw err=EOF quoted="a \"b\"" empty=""` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+// newHandler writes without the header of log.LstdFlags, so that the output does not depend on the time of the write
+func newHandler(w io.Writer, options *HandlerOptions) *Handler {
+	return NewHandlerFromLogger(log.New(w, "", 0), options)
+}
+
+// recordPC returns the PC of its caller, which is not on the stack when the record is handled
+func recordPC() uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	return pcs[0]
+}
+
+func TestHandlerFromLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := log.New(io.Discard, "app: ", log.Ldate|log.Lshortfile)
+	h := NewHandlerFromLogger(l, &HandlerOptions{}).WithGroup("s")
+
+	// the prefix, the flags and the output of the logger are read on every record,
+	// the file of the header is the caller at the PC of the record
+	l.SetOutput(buf)
+	_, _, line, _ := runtime.Caller(0)
+	slog.New(h).Info("message", "a", 1)
+
+	// without the PC on the stack, the file is the caller of Handle
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", recordPC())
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	// without the time in the header, the time of the record is written with the attrs
+	l.SetFlags(log.Lmsgprefix)
+	r = slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := regexp.MustCompile(fmt.Sprintf(`^app: \d{4}/\d{2}/\d{2} handler_test.go:%d: INFO message s.a=1\n`+
+		`app: \d{4}/\d{2}/\d{2} handler_test.go:%d: INFO message\n`+
+		`app: INFO message time=2023-10-16T12:00:00Z s.a=1\n$`, line+1, line+5))
+	if got := buf.String(); !want.MatchString(got) {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestConcurrentPrint(t *testing.T) {
+	// the records are written under the lock of the logger, with the writes of Print
+	var buf bytes.Buffer
+	l := log.New(&buf, "", 0)
+	h := NewHandlerFromLogger(l, &HandlerOptions{})
+	count := 1000
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			l.Print("hello from print")
+		}()
+		go func() {
+			defer wg.Done()
+			if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from handle", 0)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, want := range []string{"hello from print\n", "INFO hello from handle\n"} {
+		if n := strings.Count(buf.String(), want); n != count {
+			t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+		}
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	count := 1000
+	for _, isJSON := range []bool{false, true} {
+		t.Run(fmt.Sprintf("json=%t", isJSON), func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewHandler(&buf, &HandlerOptions{JSONFormatter: isJSON})
+			sub1 := h.WithAttrs([]slog.Attr{slog.Bool("sub1", true)})
+			sub2 := h.WithAttrs([]slog.Attr{slog.Bool("sub2", true)})
+			var wg sync.WaitGroup
+			for i := 0; i < count; i++ {
+				sub1Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub1", 0)
+				sub1Record.AddAttrs(slog.Int("i", i))
+				sub2Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub2", 0)
+				sub2Record.AddAttrs(slog.Int("i", i))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := sub1.Handle(ctx, sub1Record); err != nil {
+						t.Error(err)
+					}
+					if err := sub2.Handle(ctx, sub2Record); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			for i := 1; i <= 2; i++ {
+				want := "hello from sub" + strconv.Itoa(i)
+				n := strings.Count(buf.String(), want)
+				if n != count {
+					t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+				}
+			}
+		})
+	}
+}
+
+var messageRegexp = regexp.MustCompile(`^([A-Z]+) (.*?)( [^ ="]+=.*)?$`)
+
+// parseLine parses a line without header, the level, the message and the logfmt attrs,
+// or the JSON object when isJSON is true
+func parseLine(line []byte, isJSON bool) (map[string]any, error) {
+	var m map[string]any
+	if isJSON {
+		var err error
+		if m, err = conformance.ParseJSONLine(line); err != nil {
+			return nil, err
+		}
+	} else {
+		body := messageRegexp.FindStringSubmatch(string(line))
+		if body == nil {
+			return nil, errors.New("no level and message")
+		}
+		var err error
+		if m, err = conformance.ParseLogfmtLine([]byte(body[3])); err != nil {
+			return nil, err
+		}
+		m = conformance.Unflatten(m, ".")
+		m[slog.LevelKey] = body[1]
+		m[slog.MessageKey] = body[2]
+	}
+	return conformance.Rename(m, map[string]string{fileKey: slog.SourceKey}), nil
+}
+
+func TestConformance(t *testing.T) {
+	for _, isJSON := range []bool{false, true} {
+		isJSON := isJSON
+		t.Run(fmt.Sprintf("json=%t", isJSON), func(t *testing.T) {
+			conformance.Run(t, conformance.Backend{
+				NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+					return newHandler(w, &HandlerOptions{AddSource: addSource, JSONFormatter: isJSON})
+				},
+				Parse: func(out []byte) ([]map[string]any, error) {
+					return conformance.ParseLines(out, func(line []byte) (map[string]any, error) {
+						return parseLine(line, isJSON)
+					})
+				},
+				NewHandlerWithOptions: func(w io.Writer, options conformance.Options) slog.Handler {
+					h := NewHandler(w, &HandlerOptions{
+						JSONFormatter:      isJSON,
+						Level:              options.Level,
+						StacktraceLevel:    options.StacktraceLevel,
+						ReplaceAttr:        options.ReplaceAttr,
+						DuplicateKeyPolicy: options.DuplicateKeyPolicy,
+						ContextExtractors:  options.ContextExtractors,
+						Terminate:          options.Terminate,
+						ExitFunc:           options.ExitFunc,
+					})
+					h.logr.SetFlags(0)
+					return h
+				},
+				OwnsWriter: true,
+			})
+		})
+	}
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[string]
+		level    slog.Level
+		want     string
+	}{
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: "INFO message\n"},
+		{name: "trace", level: logger.LevelTrace, want: "TRACE message\n"},
+		{name: "below trace", level: logger.LevelTrace - 4, want: "TRACE message\n"},
+		{name: "fatal", level: logger.LevelFatal, want: "FATAL message\n"},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[string]{{Min: slog.LevelDebug, Level: "debug"}, {Min: slog.LevelInfo + 2, Level: "notice"}},
+			level:    slog.LevelInfo + 2,
+			want:     "notice message\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := newHandler(buf, &HandlerOptions{Level: logger.LevelTrace - 4, LevelMap: test.levelMap, Terminate: logger.LogOnly})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	buf := &bytes.Buffer{}
+	h := NewHandlerFromLogger(log.New(buf, "", 0), &HandlerOptions{AddSource: true, JSONFormatter: true, Keys: logger.Keys{
+		Level:   "severity",
+		Message: "message",
+		Source:  "source",
+	}})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ms[0]
+	for key, want := range map[string]any{"severity": "INFO", "message": "hello"} {
+		if got[key] != want {
+			t.Errorf("%q: got %v, want %v", key, got[key], want)
+		}
+	}
+	src, _ := got["source"].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/stdlog.TestKeys"; src["function"] != want {
+		t.Errorf("source: got %v, want function %s", got["source"], want)
+	}
+}
+
+func TestCloseFromLogger(t *testing.T) {
+	buf := &conformance.SyncBuffer{}
+	if err := logger.Close(NewHandlerFromLogger(log.New(buf, "", 0), &HandlerOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Synced != 1 || buf.Closed != 0 {
+		t.Errorf("want the writer of the caller synced and not closed, synced %d times, closed %d times", buf.Synced, buf.Closed)
+	}
+}
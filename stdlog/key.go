@@ -0,0 +1,21 @@
+package stdlog
+
+import (
+	"log/slog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+const fileKey string = "file"
+const funcKey string = "func"
+const stackKey string = "stack"
+
+// defaultKeys are the keys of log/slog, and "file" and "func" for the source,
+// the time is written in the header of log.Logger, and the text output writes the level and the message without keys
+var defaultKeys = logger.Keys{
+	Time:    slog.TimeKey,
+	Level:   slog.LevelKey,
+	Message: slog.MessageKey,
+	File:    fileKey,
+	Func:    funcKey,
+}
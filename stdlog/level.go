@@ -0,0 +1,17 @@
+package stdlog
+
+import (
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// levels between two constants of the root package are mapped to the lower one.
+var defaultLevelMap = logger.LevelMap[string]{
+	{Min: logger.LevelTrace, Level: "TRACE"},
+	{Min: logger.LevelDebug, Level: "DEBUG"},
+	{Min: logger.LevelInfo, Level: "INFO"},
+	{Min: logger.LevelWarn, Level: "WARN"},
+	{Min: logger.LevelError, Level: "ERROR"},
+	{Min: logger.LevelPanic, Level: "PANIC"},
+	{Min: logger.LevelFatal, Level: "FATAL"},
+}
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"log/slog"
+	"os"
+)
+
+// TerminateFunc is called by a handler after it wrote a record at LevelPanic or above,
+// with the level and the message of the record after ReplaceAttr.
+// The output of the handler is synced before the call.
+// It is the Terminate option of the handlers, which default to DefaultTerminate with their ExitFunc option.
+type TerminateFunc func(level slog.Level, msg string)
+
+// PanicOrExit returns the default TerminateFunc of the handlers:
+// it panics with the message below LevelFatal, and calls exit(1) at LevelFatal and above.
+func PanicOrExit(exit func(code int)) TerminateFunc {
+	return func(level slog.Level, msg string) {
+		if level >= LevelFatal {
+			exit(1)
+			return
+		}
+		panic(msg)
+	}
+}
+
+// DefaultTerminate returns the TerminateFunc of the Terminate and ExitFunc options of the handlers:
+// terminate when it is not nil, otherwise PanicOrExit(exit), with os.Exit when exit is nil.
+// A record at LevelPanic then panics with its message, as a Panic method does,
+// and a record at LevelFatal exits, as a Fatal method does; LogOnly only writes the record.
+func DefaultTerminate(terminate TerminateFunc, exit func(code int)) TerminateFunc {
+	if terminate != nil {
+		return terminate
+	}
+	if exit == nil {
+		exit = os.Exit
+	}
+	return PanicOrExit(exit)
+}
+
+// LogOnly is a TerminateFunc which does nothing, so the handlers only write the record.
+func LogOnly(slog.Level, string) {}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanicOrExit(t *testing.T) {
+	var codes []int
+	terminate := PanicOrExit(func(code int) { codes = append(codes, code) })
+
+	assert.PanicsWithValue(t, "message", func() { terminate(LevelPanic, "message") })
+	assert.PanicsWithValue(t, "message", func() { terminate(LevelFatal-1, "message") })
+	assert.Empty(t, codes)
+
+	assert.NotPanics(t, func() { terminate(LevelFatal, "message") })
+	assert.NotPanics(t, func() { terminate(LevelFatal+4, "message") })
+	assert.Equal(t, []int{1, 1}, codes)
+}
+
+func TestDefaultTerminate(t *testing.T) {
+	var codes []int
+	exit := func(code int) { codes = append(codes, code) }
+
+	assert.PanicsWithValue(t, "message", func() { DefaultTerminate(nil, exit)(LevelPanic, "message") })
+	DefaultTerminate(nil, exit)(LevelFatal, "message")
+	assert.Equal(t, []int{1}, codes)
+
+	var terminated []slog.Level
+	DefaultTerminate(func(level slog.Level, msg string) { terminated = append(terminated, level) }, exit)(LevelFatal, "message")
+	assert.Equal(t, []slog.Level{LevelFatal}, terminated)
+	assert.Equal(t, []int{1}, codes)
+}
@@ -0,0 +1,107 @@
+package zap
+
+import (
+	"context"
+	"log/slog"
+
+	"go.uber.org/zap/zapcore"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+/*
+	implement zapcore.Core
+*/
+
+var _ zapcore.Core = (*Core)(nil)
+
+// Core writes the entries of a zap.Logger to a slog.Handler, such as the handlers of the other packages,
+// so that the libraries which take a *zap.Logger share the backend of slog:
+//
+//	l := zap.New(zap.NewCoreFromSlog(handler), zap.AddCaller())
+//
+// The zap levels are the slog levels of the root package which the default HandlerOptions.LevelMap maps to them,
+// and zapcore.DPanicLevel is slog.LevelError.
+// The fields are converted to attrs in order, an ObjectMarshaler is a group, an ArrayMarshaler is a []any,
+// an error is kept as the value of its attr, and zap.Namespace opens a group which holds the following fields.
+// The name of the logger is written under the "name" key, the stack of the entry under the "stack" key,
+// and the source of the record is the PC of the caller, which zap.AddCaller enables.
+//
+// zap panics and exits by itself after a PanicLevel or FatalLevel entry, after the cores have written it,
+// so the records at or above logger.LevelPanic are passed at logger.LevelError unless CoreOptions.Terminate is set,
+// as the handlers of this repository terminate after such a record.
+type Core struct {
+	handler   slog.Handler
+	terminate bool
+}
+
+type CoreOptions struct {
+	// Terminate passes the records at or above logger.LevelPanic at their level, so that the handler terminates as well,
+	// by default they are passed at logger.LevelError, as zap panics and exits by itself.
+	Terminate bool
+}
+
+// NewCoreFromSlog returns a zapcore.Core that writes to handler.
+func NewCoreFromSlog(handler slog.Handler) *Core {
+	return NewCoreFromSlogWithOptions(handler, &CoreOptions{})
+}
+
+// NewCoreFromSlogWithOptions returns a zapcore.Core that writes to handler with options.
+func NewCoreFromSlogWithOptions(handler slog.Handler, options *CoreOptions) *Core {
+	return &Core{handler: handler, terminate: options.Terminate}
+}
+
+// level returns the slog level of a zap level, lowered to logger.LevelError from logger.LevelPanic unless terminate is set
+func (c *Core) level(level zapcore.Level) slog.Level {
+	if l := slogLevel(level); l < logger.LevelPanic || c.terminate {
+		return l
+	}
+	return logger.LevelError
+}
+
+// Enabled reports whether the handler is enabled at the slog level of the zap level.
+func (c *Core) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), c.level(level))
+}
+
+// With returns a Core whose handler has the fields as attrs,
+// a zap.Namespace is a group of the handler, see slog.Handler.WithGroup.
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	enc := fields2Attrs(fields)
+	handler := c.handler
+	for i, ns := range enc.namespaces {
+		if i > 0 {
+			handler = handler.WithGroup(ns.key)
+		}
+		if len(ns.attrs) > 0 {
+			handler = handler.WithAttrs(ns.attrs)
+		}
+	}
+	return &Core{handler: handler, terminate: c.terminate}
+}
+
+// Check adds the Core to the CheckedEntry if it is enabled at the level of the entry.
+func (c *Core) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+// Write passes the entry and the fields to the handler as a slog.Record, the error of the handler is returned.
+func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	r := slog.NewRecord(entry.Time, c.level(entry.Level), entry.Message, entry.Caller.PC)
+	if entry.LoggerName != "" {
+		r.AddAttrs(slog.String(nameKey, entry.LoggerName))
+	}
+	r.AddAttrs(fields2Attrs(fields).Attrs()...)
+	if entry.Stack != "" {
+		r.AddAttrs(slog.String(stackKey, entry.Stack))
+	}
+	return c.handler.Handle(context.Background(), r)
+}
+
+// Sync flushes the handler, see logger.Sync.
+func (c *Core) Sync() error {
+	return logger.Sync(c.handler)
+}
@@ -0,0 +1,228 @@
+package zap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+// newJSONHandler returns a slog.JSONHandler at all levels which does not write the time
+func newJSONHandler(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: logger.LevelTrace,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+}
+
+type user struct {
+	name string
+	tags []string
+}
+
+func (u user) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.name)
+	return enc.AddArray("tags", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, tag := range u.tags {
+			arr.AppendString(tag)
+		}
+		return nil
+	}))
+}
+
+func TestCoreWrite(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		fields []zap.Field
+		want   string
+	}{
+		{
+			name:   "no fields",
+			fields: nil,
+			want:   `{"level":"INFO","msg":"message"}`,
+		},
+		{
+			name: "fields",
+			fields: []zap.Field{
+				zap.Int("a", 1),
+				zap.String("b", "two"),
+				zap.Bool("c", true),
+				zap.Duration("d", time.Second),
+				zap.Float32("f", 0.5),
+				zap.Uint8("u", 8),
+				zap.ByteString("s", []byte("bytes")),
+				zap.Stringer("e", time.Millisecond),
+			},
+			want: `{"level":"INFO","msg":"message","a":1,"b":"two","c":true,"d":1000000000,"f":0.5,"u":8,"s":"bytes","e":"1ms"}`,
+		},
+		{
+			name:   "error",
+			fields: []zap.Field{zap.Error(errors.New("boom")), zap.Error(nil)},
+			want:   `{"level":"INFO","msg":"message","error":"boom"}`,
+		},
+		{
+			name:   "object",
+			fields: []zap.Field{zap.Object("user", user{name: "gopher", tags: []string{"a", "b"}})},
+			want:   `{"level":"INFO","msg":"message","user":{"name":"gopher","tags":["a","b"]}}`,
+		},
+		{
+			name:   "array of objects",
+			fields: []zap.Field{zap.Objects("users", []user{{name: "a"}, {name: "b", tags: []string{"c"}}})},
+			want:   `{"level":"INFO","msg":"message","users":[{"name":"a","tags":null},{"name":"b","tags":["c"]}]}`,
+		},
+		{
+			name:   "namespaces",
+			fields: []zap.Field{zap.Int("a", 1), zap.Namespace("g"), zap.Int("b", 2), zap.Namespace("h"), zap.Int("c", 3)},
+			want:   `{"level":"INFO","msg":"message","a":1,"g":{"b":2,"h":{"c":3}}}`,
+		},
+		{
+			name:   "empty namespace",
+			fields: []zap.Field{zap.Int("a", 1), zap.Namespace("g")},
+			want:   `{"level":"INFO","msg":"message","a":1}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			zap.New(NewCoreFromSlog(newJSONHandler(buf))).Info("message", test.fields...)
+			if got := strings.TrimSuffix(buf.String(), "\n"); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCoreWith(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := zap.New(NewCoreFromSlog(newJSONHandler(buf))).
+		With(zap.Int("pre", 0), zap.Namespace("s"), zap.Int("p1", 1)).
+		With(zap.Namespace("t"))
+	l.Info("message", zap.Int("a", 1), zap.Namespace("g"), zap.Int("b", 2))
+	l.Info("message")
+
+	want := `{"level":"INFO","msg":"message","pre":0,"s":{"p1":1,"t":{"a":1,"g":{"b":2}}}}` + "\n" +
+		`{"level":"INFO","msg":"message","pre":0,"s":{"p1":1}}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestCoreEntry(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true})
+	l := zap.New(NewCoreFromSlog(h), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)).Named("grpc")
+	l.Error("message")
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ms[0]
+	if got[nameKey] != "grpc" || got[slog.LevelKey] != "ERROR" {
+		t.Errorf("want the name and the level of the entry, got %v", got)
+	}
+	src, _ := got[slog.SourceKey].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/zap.TestCoreEntry"; src["function"] != want {
+		t.Errorf("source: got %v, want function %s", got[slog.SourceKey], want)
+	}
+	if stack, _ := got[stackKey].(string); !strings.Contains(stack, "zap.TestCoreEntry") {
+		t.Errorf("want the stack of the entry, got %q", stack)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, got[slog.TimeKey].(string)); err != nil {
+		t.Errorf("want the time of the entry, got %v", got[slog.TimeKey])
+	}
+}
+
+func TestCoreLevels(t *testing.T) {
+	core := NewCoreFromSlog(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	for level, want := range map[zapcore.Level]bool{
+		TraceLevel:          false,
+		zapcore.DebugLevel:  false,
+		zapcore.InfoLevel:   true,
+		zapcore.DPanicLevel: true,
+	} {
+		if got := core.Enabled(level); got != want {
+			t.Errorf("Enabled(%s): got %t, want %t", level, got, want)
+		}
+	}
+
+	for level, want := range map[zapcore.Level]slog.Level{
+		TraceLevel - 1:      logger.LevelTrace,
+		TraceLevel:          logger.LevelTrace,
+		zapcore.DebugLevel:  logger.LevelDebug,
+		zapcore.WarnLevel:   logger.LevelWarn,
+		zapcore.DPanicLevel: logger.LevelError,
+		zapcore.PanicLevel:  logger.LevelPanic,
+		zapcore.FatalLevel:  logger.LevelFatal,
+	} {
+		if got := slogLevel(level); got != want {
+			t.Errorf("slogLevel(%s): got %s, want %s", level, got, want)
+		}
+	}
+}
+
+func TestCorePanic(t *testing.T) {
+	var codes []int
+	buf := &bytes.Buffer{}
+	core := NewCoreFromSlog(NewHandler(buf, &HandlerOptions{JSONFormatter: true, ExitFunc: func(code int) { codes = append(codes, code) }}))
+	l := zap.New(core, zap.WithFatalHook(noExit{}))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "message" {
+				t.Errorf("want zap to panic with the message, got %v", r)
+			}
+		}()
+		l.Panic("message", zap.Int("a", 1))
+	}()
+	l.Fatal("message")
+
+	if len(codes) != 0 {
+		t.Errorf("want the handler not to exit, got %v", codes)
+	}
+	if got := strings.Count(buf.String(), `"level":"error"`); got != 2 || !strings.Contains(buf.String(), `"a":1`) {
+		t.Errorf("want the records written at error, got %s", buf.String())
+	}
+	if !core.Enabled(zapcore.FatalLevel) {
+		t.Error("want the core enabled at fatal")
+	}
+
+	buf.Reset()
+	l = zap.New(NewCoreFromSlogWithOptions(NewHandler(buf, &HandlerOptions{JSONFormatter: true, Terminate: logger.LogOnly}), &CoreOptions{Terminate: true}))
+	func() {
+		defer func() { _ = recover() }()
+		l.Panic("message")
+	}()
+	if got, want := buf.String(), `"level":"panic"`; !strings.Contains(got, want) {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+// noExit is a fatal hook which returns, zap replaces zapcore.WriteThenNoop by os.Exit
+type noExit struct{}
+
+func (noExit) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {}
+
+func TestCoreSync(t *testing.T) {
+	buf := &syncBuffer{}
+	core := NewCoreFromSlog(NewHandler(buf, &HandlerOptions{}).WithGroup("g"))
+	if err := zap.New(core).With(zap.Int("a", 1)).Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.synced != 1 {
+		t.Errorf("want the handler synced once, got %d", buf.synced)
+	}
+}
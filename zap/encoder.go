@@ -0,0 +1,284 @@
+package zap
+
+import (
+	"log/slog"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+/*
+	implement zapcore.ObjectEncoder and zapcore.ArrayEncoder
+*/
+
+var _ zapcore.ObjectEncoder = (*attrEncoder)(nil)
+var _ zapcore.ArrayEncoder = (*sliceEncoder)(nil)
+
+// namespace is a zap.Namespace and the attrs added after it, the first namespace of an encoder has no key
+type namespace struct {
+	key   string
+	attrs []slog.Attr
+}
+
+// attrEncoder converts zap fields to slog attrs in the order they are added,
+// zap.Namespace opens a group which holds the following attrs,
+// an ObjectMarshaler is a group and an ArrayMarshaler is a []any
+type attrEncoder struct {
+	namespaces []namespace
+}
+
+func newAttrEncoder() *attrEncoder {
+	return &attrEncoder{namespaces: []namespace{{}}}
+}
+
+// fields2Attrs converts fields to attrs with an attrEncoder, the errors are kept as values of the attrs
+func fields2Attrs(fields []zapcore.Field) *attrEncoder {
+	enc := newAttrEncoder()
+	for _, field := range fields {
+		if err, ok := field.Interface.(error); ok && field.Type == zapcore.ErrorType {
+			enc.add(slog.Any(field.Key, err))
+			continue
+		}
+		field.AddTo(enc)
+	}
+	return enc
+}
+
+func (enc *attrEncoder) add(attr slog.Attr) {
+	ns := &enc.namespaces[len(enc.namespaces)-1]
+	ns.attrs = append(ns.attrs, attr)
+}
+
+// Attrs returns the attrs, the namespaces are nested groups
+func (enc *attrEncoder) Attrs() []slog.Attr {
+	attrs := enc.namespaces[len(enc.namespaces)-1].attrs
+	for i := len(enc.namespaces) - 1; i > 0; i-- {
+		ns := enc.namespaces[i-1]
+		attrs = append(ns.attrs[:len(ns.attrs):len(ns.attrs)], slog.Attr{Key: enc.namespaces[i].key, Value: slog.GroupValue(attrs...)})
+	}
+	return attrs
+}
+
+func (enc *attrEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	arr := &sliceEncoder{}
+	err := marshaler.MarshalLogArray(arr)
+	enc.add(slog.Any(key, arr.values))
+	return err
+}
+
+func (enc *attrEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	obj := newAttrEncoder()
+	err := marshaler.MarshalLogObject(obj)
+	enc.add(slog.Attr{Key: key, Value: slog.GroupValue(obj.Attrs()...)})
+	return err
+}
+
+func (enc *attrEncoder) AddBinary(key string, value []byte) {
+	enc.add(slog.Any(key, value))
+}
+
+func (enc *attrEncoder) AddByteString(key string, value []byte) {
+	enc.add(slog.String(key, string(value)))
+}
+
+func (enc *attrEncoder) AddBool(key string, value bool) {
+	enc.add(slog.Bool(key, value))
+}
+
+func (enc *attrEncoder) AddComplex128(key string, value complex128) {
+	enc.add(slog.Any(key, value))
+}
+
+func (enc *attrEncoder) AddComplex64(key string, value complex64) {
+	enc.add(slog.Any(key, value))
+}
+
+func (enc *attrEncoder) AddDuration(key string, value time.Duration) {
+	enc.add(slog.Duration(key, value))
+}
+
+func (enc *attrEncoder) AddFloat64(key string, value float64) {
+	enc.add(slog.Float64(key, value))
+}
+
+func (enc *attrEncoder) AddFloat32(key string, value float32) {
+	enc.add(slog.Float64(key, float64(value)))
+}
+
+func (enc *attrEncoder) AddInt(key string, value int) {
+	enc.add(slog.Int(key, value))
+}
+
+func (enc *attrEncoder) AddInt64(key string, value int64) {
+	enc.add(slog.Int64(key, value))
+}
+
+func (enc *attrEncoder) AddInt32(key string, value int32) {
+	enc.add(slog.Int64(key, int64(value)))
+}
+
+func (enc *attrEncoder) AddInt16(key string, value int16) {
+	enc.add(slog.Int64(key, int64(value)))
+}
+
+func (enc *attrEncoder) AddInt8(key string, value int8) {
+	enc.add(slog.Int64(key, int64(value)))
+}
+
+func (enc *attrEncoder) AddString(key, value string) {
+	enc.add(slog.String(key, value))
+}
+
+func (enc *attrEncoder) AddTime(key string, value time.Time) {
+	enc.add(slog.Time(key, value))
+}
+
+func (enc *attrEncoder) AddUint(key string, value uint) {
+	enc.add(slog.Uint64(key, uint64(value)))
+}
+
+func (enc *attrEncoder) AddUint64(key string, value uint64) {
+	enc.add(slog.Uint64(key, value))
+}
+
+func (enc *attrEncoder) AddUint32(key string, value uint32) {
+	enc.add(slog.Uint64(key, uint64(value)))
+}
+
+func (enc *attrEncoder) AddUint16(key string, value uint16) {
+	enc.add(slog.Uint64(key, uint64(value)))
+}
+
+func (enc *attrEncoder) AddUint8(key string, value uint8) {
+	enc.add(slog.Uint64(key, uint64(value)))
+}
+
+func (enc *attrEncoder) AddUintptr(key string, value uintptr) {
+	enc.add(slog.Uint64(key, uint64(value)))
+}
+
+func (enc *attrEncoder) AddReflected(key string, value interface{}) error {
+	enc.add(slog.Any(key, value))
+	return nil
+}
+
+func (enc *attrEncoder) OpenNamespace(key string) {
+	enc.namespaces = append(enc.namespaces, namespace{key: key})
+}
+
+// sliceEncoder collects the values of an ArrayMarshaler, an ObjectMarshaler in an array is a map[string]any
+type sliceEncoder struct {
+	values []any
+}
+
+func (enc *sliceEncoder) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	arr := &sliceEncoder{}
+	err := marshaler.MarshalLogArray(arr)
+	enc.values = append(enc.values, arr.values)
+	return err
+}
+
+func (enc *sliceEncoder) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	obj := newAttrEncoder()
+	err := marshaler.MarshalLogObject(obj)
+	enc.values = append(enc.values, attrs2Map(obj.Attrs()))
+	return err
+}
+
+// attrs2Map converts attrs to a map, the groups are nested maps
+func attrs2Map(attrs []slog.Attr) map[string]any {
+	m := make(map[string]any, len(attrs))
+	for _, attr := range attrs {
+		if attr.Value.Kind() == slog.KindGroup {
+			m[attr.Key] = attrs2Map(attr.Value.Group())
+			continue
+		}
+		m[attr.Key] = attr.Value.Any()
+	}
+	return m
+}
+
+func (enc *sliceEncoder) AppendReflected(value interface{}) error {
+	enc.values = append(enc.values, value)
+	return nil
+}
+
+func (enc *sliceEncoder) AppendBool(value bool) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendByteString(value []byte) {
+	enc.values = append(enc.values, string(value))
+}
+
+func (enc *sliceEncoder) AppendComplex128(value complex128) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendComplex64(value complex64) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendDuration(value time.Duration) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendFloat64(value float64) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendFloat32(value float32) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendInt(value int) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendInt64(value int64) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendInt32(value int32) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendInt16(value int16) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendInt8(value int8) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendString(value string) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendTime(value time.Time) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendUint(value uint) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendUint64(value uint64) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendUint32(value uint32) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendUint16(value uint16) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendUint8(value uint8) {
+	enc.values = append(enc.values, value)
+}
+
+func (enc *sliceEncoder) AppendUintptr(value uintptr) {
+	enc.values = append(enc.values, value)
+}
@@ -2,14 +2,15 @@ package zap
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"runtime"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	logger "github.com/m40Jc001/slog-handler-adapter"
 	"github.com/m40Jc001/slog-handler-adapter/helper"
 )
 
@@ -18,20 +19,63 @@ import (
 */
 
 var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
 
 type Handler struct {
-	core      zapcore.Core
+	base      zapcore.Core // the core without the attrs of WithAttrs
+	core      zapcore.Core // the core with the attrs of WithAttrs outside the groups, in JSON mode, or all of them
+	name      string
 	addSource bool
 	isJSON    bool
 	level     *slog.LevelVar
-	attrGroup *helper.AttrGroup
+	scope     *helper.Scope
+	prefix    string        // the keys of the open groups in text mode, joined by "."
+	fields    [][]zap.Field // the fields of WithAttrs in each open group in JSON mode
+	levelMap  logger.LevelMap[zapcore.Level]
+	replace   func(groups []string, a slog.Attr) slog.Attr
+	keys      logger.Keys
+	dupPolicy logger.DuplicateKeyPolicy
+	terminate logger.TerminateFunc
+	ctxAttrs  []logger.ContextExtractor
+	stack     slog.Leveler
+
+	// fromCore is set when the core was supplied by the caller,
+	// time and source are then carried on the zapcore.Entry, so that the core's encoder
+	// renders them with its own keys instead of receiving them as fields
+	fromCore bool
+
+	// out is the writer of NewHandler
+	out io.Writer
 }
 
 type HandlerOptions struct {
 	AddSource        bool
 	JSONFormatter    bool
 	Level            slog.Level
-	EnableStacktrace bool
+	EnableStacktrace bool // the same as a StacktraceLevel of slog.LevelError, if StacktraceLevel is nil
+
+	// StacktraceLevel carries the stack trace on the zapcore.Entry, so the encoder writes it under the "stack" key,
+	// or the StacktraceKey of a core supplied by the caller.
+	StacktraceLevel slog.Leveler
+
+	// LevelMap maps slog levels to zap levels,
+	// by default the levels between two constants of the root package are mapped to the lower one.
+	LevelMap logger.LevelMap[zapcore.Level]
+
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the built-in attributes,
+	// the defaults are "time", "level", "msg", "file" and "func".
+	Keys logger.Keys
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+
+	// Terminate is called even if the core dropped the record, as zap.Logger does for Panic and Fatal.
+	Terminate logger.TerminateFunc
+
+	ExitFunc func(code int)
 }
 
 // NewHandler
@@ -39,8 +83,10 @@ type HandlerOptions struct {
 //
 // here, during the creation of a NewHandler, a simple wrapper is used with zapcore.Lock and zapcore.AddSync.
 //
-// Of course, zapcore.Core is passed as a parameter to NewHandler, and I believe this is a viable approach.
+// To reuse an existing zap setup, see NewHandlerFromCore and NewHandlerFromLogger.
 func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
+	keys := options.Keys.WithDefaults(defaultKeys)
+
 	var encoding string
 	if options.JSONFormatter {
 		encoding = "json"
@@ -48,15 +94,15 @@ func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
 		encoding = "console"
 	}
 	cfg := zap.Config{
-		Level:             zap.NewAtomicLevelAt(zapcore.DebugLevel),
+		Level:             zap.NewAtomicLevelAt(TraceLevel), // control by "Enable" function
 		Development:       false,
 		DisableCaller:     true,
-		DisableStacktrace: options.EnableStacktrace,
+		DisableStacktrace: true, // added by "Handle" function, see StacktraceLevel
 		Sampling:          nil,
 		Encoding:          encoding,
 		EncoderConfig: zapcore.EncoderConfig{
-			MessageKey:          msgKey,
-			LevelKey:            levelKey,
+			MessageKey:          keys.Message,
+			LevelKey:            keys.Level,
 			TimeKey:             "",
 			NameKey:             nameKey,
 			CallerKey:           callerKey,
@@ -86,29 +132,90 @@ func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
 
 	core := zapcore.NewCore(
 		encoder,
-		zapcore.Lock(zapcore.AddSync(writer)),
+		zapcore.Lock(writeSyncer{writer}),
 		zapcore.Level(cfg.Level.Level()),
 	)
 
+	h := newHandler(core, "", false, options)
+	h.out = writer
+	return h
+}
+
+// NewHandlerFromCore wraps an existing zapcore.Core, such as a tee, a sampler or a core with a custom encoder.
+//
+// The core's own level and sampling still apply, so records that slog lets through may be dropped by the core.
+// Time and source are handed to the core on the zapcore.Entry and rendered by its encoder,
+// which means the core's EncoderConfig decides their keys and formats,
+// as well as the keys of the level and the message, unless HandlerOptions.Keys.Source is set,
+// then the source is written as a field under that key.
+// JSONFormatter only selects how groups are converted to fields: nested objects or dotted keys.
+func NewHandlerFromCore(core zapcore.Core, options *HandlerOptions) *Handler {
+	return newHandler(core, "", true, options)
+}
+
+// NewHandlerFromLogger wraps the core and the name of an existing *zap.Logger, see NewHandlerFromCore.
+//
+// Options of the logger that are applied by its own methods, such as AddCaller, AddStacktrace or Hooks
+// registered through zap.WrapCore, are kept only if they are part of the core.
+func NewHandlerFromLogger(logger *zap.Logger, options *HandlerOptions) *Handler {
+	return newHandler(logger.Core(), logger.Name(), true, options)
+}
+
+func newHandler(core zapcore.Core, name string, fromCore bool, options *HandlerOptions) *Handler {
 	levelar := &slog.LevelVar{}
 	levelar.Set(options.Level)
 
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	stack := options.StacktraceLevel
+	if stack == nil && options.EnableStacktrace {
+		stack = slog.LevelError
+	}
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
 	return &Handler{
+		base:      core,
 		core:      core,
+		name:      name,
 		addSource: options.AddSource,
 		level:     levelar,
-		attrGroup: &helper.AttrGroup{},
+		scope:     helper.NewScope(),
+		levelMap:  levelMap,
+		replace:   options.ReplaceAttr,
+		dupPolicy: options.DuplicateKeyPolicy,
+		terminate: terminate,
+		ctxAttrs:  options.ContextExtractors,
+		stack:     stack,
+		keys:      options.Keys.WithDefaults(defaultKeys),
 		isJSON:    options.JSONFormatter,
+		fromCore:  fromCore,
 	}
 }
 
 func (h *Handler) clone() *Handler {
 	return &Handler{
-		core:      h.core.With([]zapcore.Field{}),
+		base:      h.base,
+		core:      h.core,
+		name:      h.name,
 		addSource: h.addSource,
 		level:     h.level,
 		isJSON:    h.isJSON,
-		attrGroup: h.attrGroup,
+		scope:     h.scope,
+		prefix:    h.prefix,
+		fields:    h.fields,
+		levelMap:  h.levelMap,
+		replace:   h.replace,
+		dupPolicy: h.dupPolicy,
+		terminate: h.terminate,
+		ctxAttrs:  h.ctxAttrs,
+		stack:     h.stack,
+		keys:      h.keys,
+		fromCore:  h.fromCore,
+		out:       h.out,
 	}
 }
 
@@ -121,8 +228,13 @@ func (h *Handler) clone() *Handler {
 // or the method does not take a context.
 // The context is passed so Enabled can use its values
 // to make a decision.
-func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
-	return h.level.Level() >= level
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		return level >= override
+	}
+	return level >= h.level.Level()
 }
 
 // Handle handles the Record.
@@ -142,95 +254,222 @@ func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
 //   - If a group's key is empty, inline the group's Attrs.
 //   - If a group has no Attrs (even if it has a non-empty key),
 //     ignore it.
-func (h *Handler) Handle(_ context.Context, r slog.Record) error {
-	recordAttrs := []slog.Attr{}
-	r.Attrs(func(a slog.Attr) bool {
-		if a.Key == "" {
-			return true
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+
+	ent := zapcore.Entry{
+		Level:   h.levelMap.Map(b.Level),
+		Message: b.Message,
+	}
+	if h.fromCore {
+		ent.LoggerName = h.name
+	}
+
+	var builtins []zap.Field
+
+	switch {
+	case b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime && h.fromCore:
+		ent.Time = b.Time.Value.Time()
+	case b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime:
+		builtins = append(builtins, zap.Time(h.keys.Time, b.Time.Value.Time()))
+	case b.Time.Key == slog.TimeKey:
+		builtins = append(builtins, value2Field(h.keys.Time, b.Time.Value))
+	case b.Time.Key != "":
+		builtins = append(builtins, value2Field(b.Time.Key, b.Time.Value))
+	}
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		switch {
+		case h.keys.Source != "" && h.isJSON:
+			builtins = append(builtins, zap.Object(h.keys.Source, source{src}))
+		case h.keys.Source != "":
+			builtins = append(builtins,
+				zap.String(h.keys.Source+".function", src.Function),
+				zap.String(h.keys.Source+".file", src.File),
+				zap.Int(h.keys.Source+".line", src.Line),
+			)
+		case h.fromCore:
+			ent.Caller = zapcore.EntryCaller{
+				Defined:  true,
+				PC:       r.PC,
+				File:     src.File,
+				Line:     src.Line,
+				Function: src.Function,
+			}
+		default:
+			builtins = append(builtins,
+				zap.String(h.keys.File, fmt.Sprintf("%s:%d", src.File, src.Line)),
+				zap.String(h.keys.Func, src.Function),
+			)
 		}
+	} else if b.Source.Key != "" {
+		builtins = append(builtins, value2Field(b.Source.Key, b.Source.Value))
+	}
+
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
 		recordAttrs = append(recordAttrs, a)
 		return true
 	})
 
-	var fields []zap.Field
-	var err error
+	attrs, err := h.scope.Normalize(recordAttrs, h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
 
-	if h.isJSON {
-		fields, err = attrs2JSONLogrusField(h.attrGroup.WithAttrs(recordAttrs).Attrs())
-	} else {
-		fields, err = attrs2TextLogrusField(h.attrGroup.WithAttrs(recordAttrs).Attrs())
+	// a key repeated across WithAttrs and the record needs all the attrs to apply the policy,
+	// they are then converted on the core without the fields of WithAttrs
+	core, conflict := h.core, h.scope.Conflict(attrs)
+	if conflict {
+		core = h.base
+		if attrs, err = h.scope.Attrs(attrs, h.dupPolicy); err != nil {
+			return err
+		}
 	}
 
-	if err != nil {
-		return err
+	// Check lets the core apply its own level and sampling before the fields are built,
+	// the attrs are normalized first, as they decide which core writes the record
+	if ce := core.Check(ent, nil); ce != nil {
+		if h.stack != nil && b.Level >= h.stack.Level() {
+			ce.Entry.Stack = helper.Stack(r.PC, 1)
+		}
+
+		var fields []zap.Field
+		switch {
+		case h.isJSON && conflict:
+			fields = attrs2JSONLogrusField(attrs)
+		case h.isJSON:
+			fields = h.nest(attrs2JSONLogrusField(attrs))
+		case conflict:
+			fields = attrs2TextLogrusField("", attrs)
+		default:
+			fields = attrs2TextLogrusField(h.prefix, attrs)
+		}
+
+		// the core never panics or exits by itself, only zap.Logger does, see Terminate
+		ce.Write(append(fields, builtins...)...)
 	}
 
-	if !r.Time.IsZero() {
-		fields = append(fields, zap.Time(timeKey, r.Time))
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
 	}
+	return nil
+}
 
-	if h.addSource && r.PC != 0 {
-		fs := runtime.CallersFrames([]uintptr{r.PC})
-		f, _ := fs.Next()
-		fields = append(fields,
-			zap.String(fileKey, fmt.Sprintf("%s:%d", f.File, f.Line)),
-			zap.String(funcKey, f.Function),
-		)
+// nest puts the fields of a record into the open groups, with the fields of WithAttrs,
+// a group without fields is left out
+func (h *Handler) nest(fields []zap.Field) []zap.Field {
+	groups := h.scope.Groups()
+	for i := len(h.fields) - 1; i >= 0; i-- {
+		inner := append(h.fields[i][:len(h.fields[i]):len(h.fields[i])], fields...)
+		if len(inner) == 0 {
+			fields = nil
+			continue
+		}
+		fields = []zap.Field{zap.Object(groups[i], fieldGroup(inner))}
 	}
+	return fields
+}
 
-	return h.core.Write(zapcore.Entry{
-		Level:   level2ZapLevel(r.Level),
-		Message: r.Message,
-	}, fields)
+// fieldGroup writes converted fields as a nested object
+type fieldGroup []zap.Field
+
+func (g fieldGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, f := range g {
+		f.AddTo(enc)
+	}
+	return nil
 }
 
-func attrs2TextLogrusField(attrs []slog.Attr) (m []zap.Field, err error) {
-	m = []zap.Field{}
-	dupKeyMap := map[string]struct{}{}
-	var rec func(prefix string, attrs []slog.Attr) error
-	rec = func(prefix string, attrs []slog.Attr) error {
-		for _, attr := range attrs {
-			key := prefix + attr.Key
-			if _, ok := dupKeyMap[key]; ok {
-				return fmt.Errorf("dup key: %s", attr.Key)
-			} else {
-				dupKeyMap[key] = struct{}{}
-			}
+// source writes a slog.Source as an object with the keys of its JSON form
+type source struct {
+	*slog.Source
+}
 
-			if attr.Value.Kind() == slog.KindGroup {
-				err = rec(prefix+attr.Key+".", attr.Value.Resolve().Group())
-				if err != nil {
-					return err
-				}
-			} else {
-				m = append(m, zap.Any(key, attr.Value.Any()))
-			}
-		}
-		return nil
+func (s source) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", s.Function)
+	enc.AddString("file", s.File)
+	enc.AddInt("line", s.Line)
+	return nil
+}
+
+// attrs2TextLogrusField converts normalized attrs, the keys are prefixed by the keys of their groups
+func attrs2TextLogrusField(prefix string, attrs []slog.Attr) []zap.Field {
+	flat := helper.Flatten(nil, prefix, attrs)
+	m := make([]zap.Field, 0, len(flat))
+	for _, attr := range flat {
+		m = append(m, value2Field(attr.Key, attr.Value))
 	}
-	return m, rec("", attrs)
+	return m
 }
 
-func attrs2JSONLogrusField(attrs []slog.Attr) (m []zap.Field, err error) {
-	dupKeyMap := map[string]struct{}{}
+// attrs2JSONLogrusField converts normalized attrs, groups become nested objects
+func attrs2JSONLogrusField(attrs []slog.Attr) []zap.Field {
+	m := make([]zap.Field, 0, len(attrs))
 	for _, attr := range attrs {
-		if _, ok := dupKeyMap[attr.Key]; ok {
-			return nil, fmt.Errorf("dup key: %s", attr.Key)
-		} else {
-			dupKeyMap[attr.Key] = struct{}{}
-		}
+		m = append(m, value2Field(attr.Key, attr.Value))
+	}
+	return m
+}
 
-		if attr.Value.Kind() == slog.KindGroup {
-			inner, err := attrs2JSONLogrusField(attr.Value.Resolve().Group())
-			if err != nil {
-				return nil, err
-			}
-			m = append(m, zap.Any(attr.Key, inner))
-		} else {
-			m = append(m, zap.Any(attr.Key, attr.Value.Any()))
-		}
+// value2Field converts a resolved value to the typed zap field of its kind, without reflection
+func value2Field(key string, v slog.Value) zap.Field {
+	switch v.Kind() {
+	case slog.KindString:
+		return zap.String(key, v.String())
+	case slog.KindInt64:
+		return zap.Int64(key, v.Int64())
+	case slog.KindUint64:
+		return zap.Uint64(key, v.Uint64())
+	case slog.KindFloat64:
+		return zap.Float64(key, v.Float64())
+	case slog.KindBool:
+		return zap.Bool(key, v.Bool())
+	case slog.KindDuration:
+		return zap.Duration(key, v.Duration())
+	case slog.KindTime:
+		return zap.Time(key, v.Time())
+	case slog.KindGroup:
+		return zap.Object(key, group(v.Group()))
+	default:
+		return zap.Any(key, v.Any())
+	}
+}
+
+// group writes normalized attrs as a nested object
+type group []slog.Attr
+
+func (g group) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, attr := range g {
+		value2Field(attr.Key, attr.Value).AddTo(enc)
 	}
-	return
+	return nil
+}
+
+// Sync flushes the core, the writer of NewHandler is synced by logger.SyncWriter.
+// The core is shared with the handlers derived by WithAttrs and WithGroup.
+func (h *Handler) Sync() error {
+	return h.core.Sync()
+}
+
+// Close syncs the core and closes the writer of NewHandler, see logger.CloseWriter,
+// the core of NewHandlerFromCore and NewHandlerFromLogger is only synced, as it is owned by the caller.
+func (h *Handler) Close() error {
+	return errors.Join(h.Sync(), logger.CloseWriter(h.out))
+}
+
+// writeSyncer is the zapcore.WriteSyncer of NewHandler,
+// it ignores the errors of writers which cannot be synced, such as terminals
+type writeSyncer struct {
+	io.Writer
+}
+
+func (w writeSyncer) Sync() error {
+	return logger.SyncWriter(w.Writer)
 }
 
 // WithAttrs returns a new Handler whose attributes consist of
@@ -238,7 +477,20 @@ func attrs2JSONLogrusField(attrs []slog.Attr) (m []zap.Field, err error) {
 // The Handler owns the slice: it may retain, modify or discard it.
 func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	cp := h.clone()
-	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	scope, attrs := h.scope.WithAttrs(attrs, h.replace)
+	cp.scope = scope
+	switch {
+	case len(attrs) == 0:
+	case h.isJSON && len(h.fields) > 0:
+		// the fields are kept until a record closes the group
+		last := len(h.fields) - 1
+		group := h.fields[last]
+		cp.fields = append(h.fields[:last:last], append(group[:len(group):len(group)], attrs2JSONLogrusField(attrs)...))
+	case h.isJSON:
+		cp.core = h.core.With(attrs2JSONLogrusField(attrs))
+	default:
+		cp.core = h.core.With(attrs2TextLogrusField(h.prefix, attrs))
+	}
 	return cp
 }
 
@@ -262,7 +514,15 @@ func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 //
 // If the name is empty, WithGroup returns the receiver.
 func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
 	cp := h.clone()
-	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	cp.scope = h.scope.WithGroup(name)
+	if h.isJSON {
+		cp.fields = append(h.fields[:len(h.fields):len(h.fields)], nil)
+	} else {
+		cp.prefix = h.prefix + name + "."
+	}
 	return cp
 }
@@ -3,12 +3,23 @@ package zap
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
 )
 
 /*
@@ -60,7 +71,7 @@ func TestDefaultHandle(t *testing.T) {
 			name:  "group",
 			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
 			attrs: attrs,
-			want: `info message {"s.a": 1, "s.b": "two", "pre": 0}
+			want: `info message {"pre": 0, "s.a": 1, "s.b": "two"}
 `,
 		},
 		{
@@ -72,7 +83,7 @@ func TestDefaultHandle(t *testing.T) {
 					WithGroup("s2")
 			},
 			attrs: attrs,
-			want: `info message {"s1.p2": 2, "s1.s2.a": 1, "s1.s2.b": "two", "p1": 1}
+			want: `info message {"p1": 1, "s1.p2": 2, "s1.s2.a": 1, "s1.s2.b": "two"}
 `,
 		},
 		{
@@ -83,7 +94,7 @@ func TestDefaultHandle(t *testing.T) {
 					WithGroup("s2")
 			},
 			attrs: attrs,
-			want: `info message {"s1.s2.a": 1, "s1.s2.b": "two", "p1": 1}
+			want: `info message {"p1": 1, "s1.s2.a": 1, "s1.s2.b": "two"}
 `,
 		},
 	} {
@@ -112,6 +123,73 @@ func TestDefaultHandle(t *testing.T) {
 	}
 }
 
+func TestJSONHandle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{JSONFormatter: true}).
+		WithAttrs([]slog.Attr{slog.Int("pre", 0)}).
+		WithGroup("s")
+	r := slog.NewRecord(time.Time{}, slog.LevelWarn, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.String("b", "two"), slog.Duration("d", time.Second)))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"level":"warn","msg":"message","pre":0,"s":{"a":1,"g":{"b":"two","d":1}}}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestHandlerFromCore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	h := NewHandlerFromLogger(zap.New(core).Named("app"), &HandlerOptions{AddSource: true, JSONFormatter: true}).
+		WithAttrs([]slog.Attr{slog.Int("pre", 0)})
+
+	debug := slog.NewRecord(now, slog.LevelDebug, "dropped by the core", pcs[0])
+	if err := h.Handle(ctx, debug); err != nil {
+		t.Fatal(err)
+	}
+	info := slog.NewRecord(now, slog.LevelInfo, "message", pcs[0])
+	info.AddAttrs(slog.Int("a", 1))
+	if err := h.Handle(ctx, info); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("want 1 entry, got %d", len(entries))
+	}
+	got := entries[0]
+	if got.Message != "message" || got.Level != zapcore.InfoLevel || got.LoggerName != "app" {
+		t.Errorf("unexpected entry %+v", got.Entry)
+	}
+	if !got.Time.Equal(now) {
+		t.Errorf("want time %v, got %v", now, got.Time)
+	}
+	if !got.Caller.Defined || got.Caller.Function != "github.com/m40Jc001/slog-handler-adapter/zap.TestHandlerFromCore" {
+		t.Errorf("unexpected caller %+v", got.Caller)
+	}
+	if want := map[string]any{"a": int64(1), "pre": int64(0)}; !equalContext(got.ContextMap(), want) {
+		t.Errorf("\ngot  %v\nwant %v", got.ContextMap(), want)
+	}
+}
+
+func equalContext(got, want map[string]any) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for k, v := range want {
+		if got[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
 func TestConcurrentWrites(t *testing.T) {
 	ctx := context.Background()
 	count := 1000
@@ -157,3 +235,353 @@ func TestConcurrentWrites(t *testing.T) {
 		})
 	}
 }
+
+// parseConsoleLine parses a line of the console encoder: the level, the message and the fields as JSON
+func parseConsoleLine(line []byte) (map[string]any, error) {
+	level, rest, _ := strings.Cut(string(line), " ")
+	m := map[string]any{}
+	msg := rest
+	for i := strings.Index(rest, " {"); i >= 0; {
+		if fields, err := conformance.ParseJSONLine([]byte(rest[i+1:])); err == nil {
+			m, msg = fields, rest[:i]
+			break
+		}
+		next := strings.Index(rest[i+1:], " {")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	m = conformance.Unflatten(m, ".")
+	m[slog.LevelKey] = level
+	m[slog.MessageKey] = msg
+	return m, nil
+}
+
+func TestConformance(t *testing.T) {
+	keys := map[string]string{timeKey: slog.TimeKey, fileKey: slog.SourceKey}
+	for _, isJSON := range []bool{false, true} {
+		isJSON := isJSON
+		t.Run(fmt.Sprintf("json=%t", isJSON), func(t *testing.T) {
+			conformance.Run(t, conformance.Backend{
+				NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+					return NewHandler(w, &HandlerOptions{AddSource: addSource, JSONFormatter: isJSON})
+				},
+				Parse: func(out []byte) ([]map[string]any, error) {
+					var ms []map[string]any
+					var err error
+					if isJSON {
+						ms, err = conformance.ParseJSON(out)
+					} else {
+						ms, err = conformance.ParseLines(out, parseConsoleLine)
+					}
+					for i := range ms {
+						ms[i] = conformance.Rename(ms[i], keys)
+						if isJSON {
+							ms[i] = conformance.Rename(ms[i], map[string]string{msgKey: slog.MessageKey})
+						}
+					}
+					return ms, err
+				},
+			})
+		})
+	}
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[zapcore.Level]
+		level    slog.Level
+		want     string
+	}{
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: "info message\n"},
+		{name: "above error", level: slog.LevelError + 2, want: "error message\n"},
+		{name: "below trace", level: logger.LevelTrace - 4, want: "trace message\n"},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[zapcore.Level]{{Min: slog.LevelDebug, Level: zapcore.DebugLevel}, {Min: slog.LevelInfo + 2, Level: zapcore.WarnLevel}},
+			level:    slog.LevelInfo + 2,
+			want:     "warn message\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(buf, &HandlerOptions{Level: logger.LevelTrace - 4, LevelMap: test.levelMap})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplaceAttr(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		switch {
+		case a.Key == slog.TimeKey && len(groups) == 0:
+			return slog.Int64("ts", a.Value.Time().Unix())
+		case a.Key == slog.MessageKey && len(groups) == 0:
+			return slog.String(a.Key, strings.ToUpper(a.Value.String()))
+		case a.Key == "secret":
+			return slog.Attr{}
+		case a.Key == "b":
+			return slog.String(strings.Join(append(groups, a.Key), "_"), a.Value.String())
+		}
+		return a
+	}}).WithAttrs([]slog.Attr{slog.String("secret", "hidden")}).WithGroup("s")
+
+	r := slog.NewRecord(time.Unix(1697457600, 0), slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Group("g", slog.String("b", "two"), slog.String("secret", "hidden")))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `info MESSAGE {"s.g.s_g_b": "two", "ts": 1697457600}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{AddSource: true, JSONFormatter: true, Keys: logger.Keys{
+		Time:    "@timestamp",
+		Level:   "severity",
+		Message: "message",
+		Source:  "caller",
+	}})
+	now := time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC)
+	r := slog.NewRecord(now, slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ms[0]
+	for key, want := range map[string]any{"@timestamp": float64(now.UnixNano()), "severity": "info", "message": "hello"} {
+		if got[key] != want {
+			t.Errorf("%q: got %v, want %v", key, got[key], want)
+		}
+	}
+	src, _ := got["caller"].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/zap.TestKeys"; src["function"] != want {
+		t.Errorf("caller: got %v, want function %s", got["caller"], want)
+	}
+}
+
+func TestDuplicateKeyPolicy(t *testing.T) {
+	ctx := context.Background()
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.Int("b", 1)), slog.Int("a", 2))
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Group("g", slog.Int("b", 0))})
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "info message {\"g.b\": 1, \"a\": 2}\n"; got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+
+	buf.Reset()
+	h = NewHandler(buf, &HandlerOptions{DuplicateKeyPolicy: logger.DuplicateKeyError})
+	if err := h.Handle(ctx, r); err == nil || err.Error() != "dup key: a" {
+		t.Errorf("want a dup key error, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("want no output, got %s", buf.String())
+	}
+}
+
+func TestWithAttrsJSON(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2").
+					WithGroup("s3")
+			},
+			attrs: []slog.Attr{slog.Group("s4"), slog.Int("a", 1)},
+			want:  `{"level":"info","msg":"message","p1":1,"s1":{"p2":2,"s2":{"s3":{"a":1}}}}`,
+		},
+		{
+			name: "repeated group",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Group("g", slog.Int("b", 0))})
+			},
+			attrs: []slog.Attr{slog.Group("g", slog.Int("b", 1), slog.Int("c", 2))},
+			want:  `{"level":"info","msg":"message","g":{"b":1,"c":2}}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := test.with(NewHandler(buf, &HandlerOptions{JSONFormatter: true}))
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want+"\n" {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+// syncBuffer records the calls of Sync and Close
+type syncBuffer struct {
+	bytes.Buffer
+	synced, closed int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced++
+	return nil
+}
+
+func (b *syncBuffer) Close() error {
+	b.closed++
+	return nil
+}
+
+func TestSyncClose(t *testing.T) {
+	buf := &syncBuffer{}
+	h := NewHandler(buf, &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g")
+	if err := logger.Sync(h); err != nil {
+		t.Fatal(err)
+	}
+	if buf.synced != 1 || buf.closed != 0 {
+		t.Errorf("after Sync: synced %d times, closed %d times", buf.synced, buf.closed)
+	}
+	if err := logger.Close(h); err != nil {
+		t.Fatal(err)
+	}
+	if buf.synced != 2 || buf.closed != 1 {
+		t.Errorf("after Close: synced %d times, closed %d times", buf.synced, buf.closed)
+	}
+}
+
+func TestCloseStderr(t *testing.T) {
+	h := NewHandler(os.Stderr, &HandlerOptions{})
+	if err := logger.Close(h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stderr.Stat(); err != nil {
+		t.Errorf("want stderr left open, got %v", err)
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	ctx := context.Background()
+	var codes []int
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{ExitFunc: func(code int) { codes = append(codes, code) }})
+
+	func() {
+		defer func() {
+			if r := recover(); r != "message" {
+				t.Errorf("want a panic with the message, got %v", r)
+			}
+		}()
+		_ = h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelPanic, "message", 0))
+	}()
+	if err := h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelFatal, "message", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(codes) != "[1]" {
+		t.Errorf("want exit(1) once, got %v", codes)
+	}
+	if n := strings.Count(buf.String(), "message"); n != 2 {
+		t.Errorf("want both records written, got %s", buf.String())
+	}
+
+	var levels []slog.Level
+	h = NewHandler(io.Discard, &HandlerOptions{Terminate: func(level slog.Level, msg string) { levels = append(levels, level) }})
+	for _, level := range []slog.Level{slog.LevelError, logger.LevelPanic, logger.LevelFatal + 4} {
+		if err := h.Handle(ctx, slog.NewRecord(time.Time{}, level, "message", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := fmt.Sprint([]slog.Level{logger.LevelPanic, logger.LevelFatal + 4}); fmt.Sprint(levels) != want {
+		t.Errorf("want Terminate called with %s, got %v", want, levels)
+	}
+	levels = nil
+	core, logs := observer.New(zapcore.FatalLevel)
+	h = NewHandlerFromCore(core, &HandlerOptions{Terminate: func(level slog.Level, msg string) { levels = append(levels, level) }})
+	if err := h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelPanic, "message", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Len() != 0 || len(levels) != 1 {
+		t.Errorf("want the record dropped by the core and Terminate called, got %d entries and %v", logs.Len(), levels)
+	}
+}
+
+type requestIDKey struct{}
+
+func TestContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{ContextExtractors: []logger.ContextExtractor{
+		func(ctx context.Context) []slog.Attr {
+			if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+				return []slog.Attr{slog.String("request_id", id)}
+			}
+			return nil
+		},
+	}})
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "r1")
+	if h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want debug disabled without a context level")
+	}
+	ctx = logger.ContextWithLevel(ctx, slog.LevelDebug)
+	if !h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want debug enabled by the context level")
+	}
+
+	r := slog.NewRecord(time.Time{}, slog.LevelDebug, "message", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "debug message {\"request_id\": \"r1\", \"a\": 1}\n"; got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestStacktrace(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := slog.New(NewHandler(buf, &HandlerOptions{JSONFormatter: true, StacktraceLevel: slog.LevelError}))
+	l.Warn("no stack")
+	l.Error("stack")
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ms[0]["stack"]; ok {
+		t.Errorf("want no stack below the level, got %v", ms[0])
+	}
+	stack, _ := ms[1]["stack"].(string)
+	if want := "github.com/m40Jc001/slog-handler-adapter/zap.TestStacktrace\n\t"; !strings.HasPrefix(stack, want) {
+		t.Errorf("want the stack to start at the caller, got %q", stack)
+	}
+}
@@ -1,5 +1,7 @@
 package zap
 
+import logger "github.com/m40Jc001/slog-handler-adapter"
+
 const (
 	msgKey    string = "msg"
 	levelKey  string = "level"
@@ -10,3 +12,11 @@ const (
 	stackKey  string = "stack"
 	callerKey string = "caller"
 )
+
+var defaultKeys = logger.Keys{
+	Time:    timeKey,
+	Level:   levelKey,
+	Message: msgKey,
+	File:    fileKey,
+	Func:    funcKey,
+}
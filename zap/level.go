@@ -18,22 +18,35 @@ func lowercaseLevelEncoderAddTraceLevel(level zapcore.Level, enc zapcore.Primiti
 	zapcore.LowercaseLevelEncoder(level, enc)
 }
 
-func level2ZapLevel(level slog.Level) zapcore.Level {
-	switch level {
-	case logger.LevelTrace:
-		return TraceLevel
-	case logger.LevelDebug:
-		return zapcore.DebugLevel
-	case logger.LevelInfo:
-		return zapcore.InfoLevel
-	case logger.LevelWarn:
-		return zapcore.WarnLevel
-	case logger.LevelError:
-		return zapcore.ErrorLevel
-	case logger.LevelPanic:
-		return zapcore.PanicLevel
-	case logger.LevelFatal:
-		return zapcore.FatalLevel
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// levels between two constants of the root package are mapped to the lower one.
+var defaultLevelMap = logger.LevelMap[zapcore.Level]{
+	{Min: logger.LevelTrace, Level: TraceLevel},
+	{Min: logger.LevelDebug, Level: zapcore.DebugLevel},
+	{Min: logger.LevelInfo, Level: zapcore.InfoLevel},
+	{Min: logger.LevelWarn, Level: zapcore.WarnLevel},
+	{Min: logger.LevelError, Level: zapcore.ErrorLevel},
+	{Min: logger.LevelPanic, Level: zapcore.PanicLevel},
+	{Min: logger.LevelFatal, Level: zapcore.FatalLevel},
+}
+
+// slogLevel is the reverse of defaultLevelMap, used by Core,
+// zapcore.DPanicLevel, which only panics in development, is slog.LevelError
+func slogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level <= TraceLevel:
+		return logger.LevelTrace
+	case level == zapcore.DebugLevel:
+		return logger.LevelDebug
+	case level == zapcore.InfoLevel:
+		return logger.LevelInfo
+	case level == zapcore.WarnLevel:
+		return logger.LevelWarn
+	case level <= zapcore.DPanicLevel:
+		return logger.LevelError
+	case level == zapcore.PanicLevel:
+		return logger.LevelPanic
+	default:
+		return logger.LevelFatal
 	}
-	return TraceLevel // TODO: Here, if the above does not map, it is converted to the lowest log level, not sure if there is a better way to do this
 }
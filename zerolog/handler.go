@@ -0,0 +1,315 @@
+package zerolog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/helper"
+)
+
+/*
+	implement log/slog.Handler
+*/
+
+var _ slog.Handler = (*Handler)(nil)
+var _ logger.Syncer = (*Handler)(nil)
+var _ logger.Closer = (*Handler)(nil)
+
+type Handler struct {
+	logr      zerolog.Logger
+	addSource bool
+	isJSON    bool
+	level     *slog.LevelVar
+	attrGroup *helper.AttrGroup
+	levelMap  logger.LevelMap[zerolog.Level]
+	replace   func(groups []string, a slog.Attr) slog.Attr
+	keys      logger.Keys
+	out       io.Writer // the writer of NewHandler
+	dupPolicy logger.DuplicateKeyPolicy
+	terminate logger.TerminateFunc
+	ctxAttrs  []logger.ContextExtractor
+}
+
+type HandlerOptions struct {
+	AddSource     bool
+	JSONFormatter bool
+	Level         slog.Level
+
+	// LevelMap maps slog levels to zerolog levels,
+	// by default the levels between two constants of the root package are mapped to the lower one.
+	LevelMap logger.LevelMap[zerolog.Level]
+
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Keys are the output keys of the built-in attributes,
+	// the defaults are the field names of zerolog and "func".
+	// When the level key differs from zerolog.LevelFieldName, the event is written without a zerolog level,
+	// so hooks and zerolog.LevelWriter see zerolog.NoLevel.
+	// The console output only recognizes the field names of zerolog, other keys are written as fields.
+	Keys logger.Keys
+
+	DuplicateKeyPolicy logger.DuplicateKeyPolicy
+	ContextExtractors  []logger.ContextExtractor
+
+	// Terminate is called by the handler, zerolog does not panic or exit by itself for its events.
+	Terminate logger.TerminateFunc
+
+	ExitFunc func(code int)
+}
+
+// NewHandler
+// zerolog writes every event with a single Write call, but does not lock the writer,
+// so the writer is wrapped with zerolog.SyncWriter.
+//
+// When JSONFormatter is false, the output goes through a zerolog.ConsoleWriter without color.
+func NewHandler(writer io.Writer, options *HandlerOptions) *Handler {
+	var w io.Writer = zerolog.SyncWriter(writer)
+	if !options.JSONFormatter {
+		w = zerolog.ConsoleWriter{
+			Out:             w,
+			NoColor:         true,
+			FormatTimestamp: formatTimestamp,
+		}
+	}
+
+	logr := zerolog.New(w).Level(zerolog.TraceLevel) // control by "Enable" function
+
+	levelar := &slog.LevelVar{}
+	levelar.Set(options.Level)
+
+	levelMap := options.LevelMap
+	if len(levelMap) == 0 {
+		levelMap = defaultLevelMap
+	}
+
+	terminate := logger.DefaultTerminate(options.Terminate, options.ExitFunc)
+
+	return &Handler{
+		logr:      logr,
+		addSource: options.AddSource,
+		level:     levelar,
+		attrGroup: &helper.AttrGroup{},
+		levelMap:  levelMap,
+		replace:   options.ReplaceAttr,
+		dupPolicy: options.DuplicateKeyPolicy,
+		terminate: terminate,
+		ctxAttrs:  options.ContextExtractors,
+		keys:      options.Keys,
+		out:       writer,
+		isJSON:    options.JSONFormatter,
+	}
+}
+
+// formatTimestamp leaves out the time part of the console output when the record has no time
+func formatTimestamp(i interface{}) string {
+	if i == nil {
+		return ""
+	}
+	return fmt.Sprint(i)
+}
+
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		logr:      h.logr,
+		addSource: h.addSource,
+		level:     h.level,
+		isJSON:    h.isJSON,
+		attrGroup: h.attrGroup,
+		levelMap:  h.levelMap,
+		replace:   h.replace,
+		dupPolicy: h.dupPolicy,
+		terminate: h.terminate,
+		ctxAttrs:  h.ctxAttrs,
+		keys:      h.keys,
+		out:       h.out,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The handler ignores records whose level is lower.
+// It is called early, before any arguments are processed,
+// to save effort if the log event should be discarded.
+// If called from a Logger method, the first argument is the context
+// passed to that method, or context.Background() if nil was passed
+// or the method does not take a context.
+// The context is passed so Enabled can use its values
+// to make a decision.
+//
+// A level set on the context by logger.ContextWithLevel overrides the level of the handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if override, ok := logger.LevelFromContext(ctx); ok {
+		return level >= override
+	}
+	return level >= h.level.Level()
+}
+
+// Handle handles the Record.
+// It will only be called when Enabled returns true.
+// The Context argument is as for Enabled.
+// It is present solely to provide Handlers access to the context's values.
+// Canceling the context should not affect record processing.
+// (Among other things, log messages may be necessary to debug a
+// cancellation-related problem.)
+//
+// Handle methods that produce output should observe the following rules:
+//   - If r.Time is the zero time, ignore the time.
+//   - If r.PC is zero, ignore it.
+//   - Attr's values should be resolved.
+//   - If an Attr's key and value are both the zero value, ignore the Attr.
+//     This can be tested with attr.Equal(Attr{}).
+//   - If a group's key is empty, inline the group's Attrs.
+//   - If a group has no Attrs (even if it has a non-empty key),
+//     ignore it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	for _, extract := range h.ctxAttrs {
+		recordAttrs = append(recordAttrs, extract(ctx)...)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+
+	attrs, err := helper.Normalize(h.attrGroup.WithAttrs(recordAttrs).Attrs(), h.replace, h.dupPolicy)
+	if err != nil {
+		return err
+	}
+
+	var fields []any
+	if h.isJSON {
+		fields = attrs2JSONZerologField(attrs)
+	} else {
+		fields = attrs2TextZerologField(attrs)
+	}
+
+	b := helper.ReplaceBuiltins(h.replace, r, h.addSource)
+	keys := h.keys.WithDefaults(defaultKeys())
+	level := h.levelMap.Map(b.Level)
+
+	var e *zerolog.Event
+	if keys.Level == zerolog.LevelFieldName {
+		e = h.logr.WithLevel(level)
+	} else {
+		e = h.logr.Log().Str(keys.Level, zerolog.LevelFieldMarshalFunc(level))
+	}
+
+	switch {
+	case b.Time.Key == slog.TimeKey && b.Time.Value.Kind() == slog.KindTime:
+		e = e.Time(keys.Time, b.Time.Value.Time())
+	case b.Time.Key == slog.TimeKey:
+		e = e.Interface(keys.Time, b.Time.Value.Any())
+	case b.Time.Key != "":
+		e = e.Interface(b.Time.Key, b.Time.Value.Any())
+	}
+
+	e = e.Fields(fields)
+
+	if src, ok := b.Source.Value.Any().(*slog.Source); ok && b.Source.Key == slog.SourceKey {
+		switch {
+		case keys.Source != "" && h.isJSON:
+			e = e.Object(keys.Source, fieldList{"function", src.Function, "file", src.File, "line", src.Line})
+		case keys.Source != "":
+			e = e.Str(keys.Source+".function", src.Function).
+				Str(keys.Source+".file", src.File).
+				Int(keys.Source+".line", src.Line)
+		default:
+			e = e.Str(keys.File, fmt.Sprintf("%s:%d", src.File, src.Line)).
+				Str(keys.Func, src.Function)
+		}
+	} else if b.Source.Key != "" {
+		e = e.Interface(b.Source.Key, b.Source.Value.Any())
+	}
+
+	if keys.Message == zerolog.MessageFieldName {
+		e.Msg(b.Message)
+	} else {
+		e.Str(keys.Message, b.Message).Send()
+	}
+
+	if b.Level >= logger.LevelPanic {
+		_ = h.Sync()
+		h.terminate(b.Level, b.Message)
+	}
+	return nil
+}
+
+// fieldList is a key/value list in the form accepted by zerolog.Event.Fields,
+// it is used to write a group as a nested JSON object
+type fieldList []any
+
+func (l fieldList) MarshalZerologObject(e *zerolog.Event) {
+	e.Fields([]any(l))
+}
+
+// attrs2TextZerologField converts normalized attrs, the keys in groups are prefixed by the group keys
+func attrs2TextZerologField(attrs []slog.Attr) []any {
+	flat := helper.Flatten(nil, "", attrs)
+	m := make([]any, 0, 2*len(flat))
+	for _, attr := range flat {
+		m = append(m, attr.Key, attr.Value.Any())
+	}
+	return m
+}
+
+// attrs2JSONZerologField converts normalized attrs, groups become nested objects
+func attrs2JSONZerologField(attrs []slog.Attr) []any {
+	m := make([]any, 0, 2*len(attrs))
+	for _, attr := range attrs {
+		if attr.Value.Kind() == slog.KindGroup {
+			m = append(m, attr.Key, fieldList(attrs2JSONZerologField(attr.Value.Group())))
+		} else {
+			m = append(m, attr.Key, attr.Value.Any())
+		}
+	}
+	return m
+}
+
+// Sync flushes the writer of NewHandler, see logger.SyncWriter.
+func (h *Handler) Sync() error {
+	return logger.SyncWriter(h.out)
+}
+
+// Close syncs the writer of NewHandler and closes it, see logger.CloseWriter.
+func (h *Handler) Close() error {
+	return errors.Join(h.Sync(), logger.CloseWriter(h.out))
+}
+
+// WithAttrs returns a new Handler whose attributes consist of
+// both the receiver's attributes and the arguments.
+// The Handler owns the slice: it may retain, modify or discard it.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithAttrs(attrs)
+	return cp
+}
+
+// WithGroup returns a new Handler with the given group appended to
+// the receiver's existing groups.
+// The keys of all subsequent attributes, whether added by With or in a
+// Record, should be qualified by the sequence of group names.
+//
+// How this qualification happens is up to the Handler, so long as
+// this Handler's attribute keys differ from those of another Handler
+// with a different sequence of group names.
+//
+// A Handler should treat WithGroup as starting a Group of Attrs that ends
+// at the end of the log event. That is,
+//
+//	logger.WithGroup("s").LogAttrs(level, msg, slog.Int("a", 1), slog.Int("b", 2))
+//
+// should behave like
+//
+//	logger.LogAttrs(level, msg, slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+//
+// If the name is empty, WithGroup returns the receiver.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	cp := h.clone()
+	cp.attrGroup = cp.attrGroup.WithGroup(name)
+	return cp
+}
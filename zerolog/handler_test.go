@@ -0,0 +1,459 @@
+package zerolog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+	"github.com/m40Jc001/slog-handler-adapter/conformance"
+)
+
+/*
+	This unit test is adapted from go 1.21.1 log/slog/handler_test.go
+	with slight modifications to test the zerolog handler
+*/
+
+func TestDefaultHandle(t *testing.T) {
+	ctx := context.Background()
+	preAttrs := []slog.Attr{slog.Int("pre", 0)}
+	attrs := []slog.Attr{slog.Int("a", 1), slog.String("b", "two")}
+	for _, test := range []struct {
+		name  string
+		with  func(h slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name: "no attrs",
+			want: "INF message\n",
+		},
+		{
+			name:  "attrs",
+			attrs: attrs,
+			want:  "INF message a=1 b=two\n",
+		},
+		{
+			name:  "preformatted",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs) },
+			attrs: attrs,
+			want:  "INF message a=1 b=two pre=0\n",
+		},
+		{
+			name: "groups",
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Group("g",
+					slog.Int("b", 2),
+					slog.Group("h", slog.Int("c", 3)),
+					slog.Int("d", 4)),
+				slog.Int("e", 5),
+			},
+			want: "INF message a=1 e=5 g.b=2 g.d=4 g.h.c=3\n",
+		},
+		{
+			name:  "group",
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs(preAttrs).WithGroup("s") },
+			attrs: attrs,
+			want:  "INF message pre=0 s.a=1 s.b=two\n",
+		},
+		{
+			name: "preformatted groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithAttrs([]slog.Attr{slog.Int("p2", 2)}).
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  "INF message p1=1 s1.p2=2 s1.s2.a=1 s1.s2.b=two\n",
+		},
+		{
+			name: "two with-groups",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p1", 1)}).
+					WithGroup("s1").
+					WithGroup("s2")
+			},
+			attrs: attrs,
+			want:  "INF message p1=1 s1.s2.a=1 s1.s2.b=two\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+
+			buf := &bytes.Buffer{}
+
+			var h slog.Handler = NewHandler(buf, &HandlerOptions{})
+
+			if test.with != nil {
+				h = test.with(h)
+			}
+
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			got = buf.String()
+			if got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJSONHandle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{JSONFormatter: true}).
+		WithAttrs([]slog.Attr{slog.Int("pre", 0)}).
+		WithGroup("s")
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelWarn, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.String("b", "two")))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"level":"warn","time":"2023-10-16T12:00:00Z","pre":0,"s":{"a":1,"g":{"b":"two"}},"message":"message"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	count := 1000
+	for _, handlerType := range []string{"text", "json"} {
+		t.Run(handlerType, func(t *testing.T) {
+			var buf bytes.Buffer
+			var h slog.Handler
+			switch handlerType {
+			case "text":
+				h = NewHandler(&buf, &HandlerOptions{})
+			case "json":
+				h = NewHandler(&buf, &HandlerOptions{JSONFormatter: true})
+			default:
+				t.Fatalf("unexpected handlerType %q", handlerType)
+			}
+			sub1 := h.WithAttrs([]slog.Attr{slog.Bool("sub1", true)})
+			sub2 := h.WithAttrs([]slog.Attr{slog.Bool("sub2", true)})
+			var wg sync.WaitGroup
+			for i := 0; i < count; i++ {
+				sub1Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub1", 0)
+				sub1Record.AddAttrs(slog.Int("i", i))
+				sub2Record := slog.NewRecord(time.Time{}, slog.LevelInfo, "hello from sub2", 0)
+				sub2Record.AddAttrs(slog.Int("i", i))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := sub1.Handle(ctx, sub1Record); err != nil {
+						t.Error(err)
+					}
+					if err := sub2.Handle(ctx, sub2Record); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			for i := 1; i <= 2; i++ {
+				want := "hello from sub" + strconv.Itoa(i)
+				n := strings.Count(buf.String(), want)
+				if n != count {
+					t.Fatalf("want %d occurrences of %q, got %d", count, want, n)
+				}
+			}
+		})
+	}
+}
+
+var consoleLevels = map[string]bool{"TRC": true, "DBG": true, "INF": true, "WRN": true, "ERR": true, "FTL": true, "PNC": true}
+
+// parseConsoleLine parses a line of zerolog.ConsoleWriter: the time, the level, the caller followed by ">",
+// the message and the fields as key=value pairs
+func parseConsoleLine(line []byte) (map[string]any, error) {
+	parts := strings.Split(string(line), " ")
+	i := 0
+	for ; i < len(parts) && !strings.Contains(parts[i], "="); i++ {
+	}
+	header := parts[:i]
+
+	m, err := conformance.ParseLogfmtLine([]byte(strings.Join(parts[i:], " ")))
+	if err != nil {
+		return nil, err
+	}
+	m = conformance.Unflatten(m, ".")
+
+	if len(header) > 0 && !consoleLevels[header[0]] {
+		m[slog.TimeKey], header = header[0], header[1:]
+	}
+	if len(header) > 0 {
+		m[slog.LevelKey], header = header[0], header[1:]
+	}
+	if len(header) > 1 && header[1] == ">" {
+		m[slog.SourceKey], header = header[0], header[2:]
+	}
+	m[slog.MessageKey] = strings.Join(header, " ")
+	return m, nil
+}
+
+func TestConformance(t *testing.T) {
+	for _, isJSON := range []bool{false, true} {
+		isJSON := isJSON
+		t.Run(fmt.Sprintf("json=%t", isJSON), func(t *testing.T) {
+			conformance.Run(t, conformance.Backend{
+				NewHandler: func(w io.Writer, addSource bool) slog.Handler {
+					return NewHandler(w, &HandlerOptions{AddSource: addSource, JSONFormatter: isJSON})
+				},
+				Parse: func(out []byte) ([]map[string]any, error) {
+					if !isJSON {
+						return conformance.ParseLines(out, parseConsoleLine)
+					}
+					ms, err := conformance.ParseJSON(out)
+					for i := range ms {
+						ms[i] = conformance.Rename(ms[i], map[string]string{
+							zerolog.MessageFieldName: slog.MessageKey,
+							zerolog.CallerFieldName:  slog.SourceKey,
+						})
+					}
+					return ms, err
+				},
+			})
+		})
+	}
+}
+
+func TestLevelMap(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		levelMap logger.LevelMap[zerolog.Level]
+		level    slog.Level
+		want     string
+	}{
+		{name: "between info and warn", level: slog.LevelWarn - 1, want: "INF message\n"},
+		{name: "above error", level: slog.LevelError + 2, want: "ERR message\n"},
+		{name: "below trace", level: logger.LevelTrace - 4, want: "TRC message\n"},
+		{
+			name:     "override",
+			levelMap: logger.LevelMap[zerolog.Level]{{Min: slog.LevelDebug, Level: zerolog.DebugLevel}, {Min: slog.LevelInfo + 2, Level: zerolog.WarnLevel}},
+			level:    slog.LevelInfo + 2,
+			want:     "WRN message\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			h := NewHandler(buf, &HandlerOptions{Level: logger.LevelTrace - 4, LevelMap: test.levelMap})
+			if err := h.Handle(ctx, slog.NewRecord(time.Time{}, test.level, "message", 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplaceAttr(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{JSONFormatter: true, ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		switch {
+		case a.Key == slog.TimeKey && len(groups) == 0:
+			return slog.Int64("ts", a.Value.Time().Unix())
+		case a.Key == slog.LevelKey && len(groups) == 0:
+			return slog.Any(a.Key, slog.LevelError)
+		case a.Key == "secret":
+			return slog.Attr{}
+		case a.Key == "b":
+			return slog.String(strings.Join(append(groups, a.Key), "_"), a.Value.String())
+		}
+		return a
+	}}).WithAttrs([]slog.Attr{slog.String("secret", "hidden")}).WithGroup("s")
+
+	r := slog.NewRecord(time.Unix(1697457600, 0), slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Group("g", slog.String("b", "two"), slog.String("secret", "hidden")))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"level":"error","ts":1697457600,"s":{"g":{"s_g_b":"two"}},"message":"message"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{AddSource: true, JSONFormatter: true, Keys: logger.Keys{
+		Time:    "@timestamp",
+		Level:   "severity",
+		Message: "message",
+		Source:  "caller",
+	}})
+	r := slog.NewRecord(time.Date(2023, 10, 16, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "hello", pcs[0])
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := conformance.ParseJSON(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ms[0]
+	for key, want := range map[string]any{"@timestamp": "2023-10-16T12:00:00Z", "severity": "info", "message": "hello"} {
+		if got[key] != want {
+			t.Errorf("%q: got %v, want %v", key, got[key], want)
+		}
+	}
+	src, _ := got["caller"].(map[string]any)
+	if want := "github.com/m40Jc001/slog-handler-adapter/zerolog.TestKeys"; src["function"] != want {
+		t.Errorf("caller: got %v, want function %s", got["caller"], want)
+	}
+}
+
+func TestDuplicateKeyPolicy(t *testing.T) {
+	ctx := context.Background()
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.Int("b", 1)), slog.Int("a", 2))
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Group("g", slog.Int("b", 0))})
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "INF message a=2 g.b=1\n"; got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+
+	buf.Reset()
+	h = NewHandler(buf, &HandlerOptions{DuplicateKeyPolicy: logger.DuplicateKeyError})
+	if err := h.Handle(ctx, r); err == nil || err.Error() != "dup key: a" {
+		t.Errorf("want a dup key error, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("want no output, got %s", buf.String())
+	}
+}
+
+// syncBuffer records the calls of Sync and Close
+type syncBuffer struct {
+	bytes.Buffer
+	synced, closed int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced++
+	return nil
+}
+
+func (b *syncBuffer) Close() error {
+	b.closed++
+	return nil
+}
+
+func TestSyncClose(t *testing.T) {
+	buf := &syncBuffer{}
+	h := NewHandler(buf, &HandlerOptions{}).WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g")
+	if err := logger.Sync(h); err != nil {
+		t.Fatal(err)
+	}
+	if buf.synced != 1 || buf.closed != 0 {
+		t.Errorf("after Sync: synced %d times, closed %d times", buf.synced, buf.closed)
+	}
+	if err := logger.Close(h); err != nil {
+		t.Fatal(err)
+	}
+	if buf.synced != 2 || buf.closed != 1 {
+		t.Errorf("after Close: synced %d times, closed %d times", buf.synced, buf.closed)
+	}
+}
+
+func TestCloseStderr(t *testing.T) {
+	h := NewHandler(os.Stderr, &HandlerOptions{})
+	if err := logger.Close(h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stderr.Stat(); err != nil {
+		t.Errorf("want stderr left open, got %v", err)
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	ctx := context.Background()
+	var codes []int
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{ExitFunc: func(code int) { codes = append(codes, code) }})
+
+	func() {
+		defer func() {
+			if r := recover(); r != "message" {
+				t.Errorf("want a panic with the message, got %v", r)
+			}
+		}()
+		_ = h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelPanic, "message", 0))
+	}()
+	if err := h.Handle(ctx, slog.NewRecord(time.Time{}, logger.LevelFatal, "message", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(codes) != "[1]" {
+		t.Errorf("want exit(1) once, got %v", codes)
+	}
+	if n := strings.Count(buf.String(), "message"); n != 2 {
+		t.Errorf("want both records written, got %s", buf.String())
+	}
+
+	var levels []slog.Level
+	h = NewHandler(io.Discard, &HandlerOptions{Terminate: func(level slog.Level, msg string) { levels = append(levels, level) }})
+	for _, level := range []slog.Level{slog.LevelError, logger.LevelPanic, logger.LevelFatal + 4} {
+		if err := h.Handle(ctx, slog.NewRecord(time.Time{}, level, "message", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := fmt.Sprint([]slog.Level{logger.LevelPanic, logger.LevelFatal + 4}); fmt.Sprint(levels) != want {
+		t.Errorf("want Terminate called with %s, got %v", want, levels)
+	}
+}
+
+type requestIDKey struct{}
+
+func TestContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewHandler(buf, &HandlerOptions{ContextExtractors: []logger.ContextExtractor{
+		func(ctx context.Context) []slog.Attr {
+			if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+				return []slog.Attr{slog.String("request_id", id)}
+			}
+			return nil
+		},
+	}})
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "r1")
+	if h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want debug disabled without a context level")
+	}
+	ctx = logger.ContextWithLevel(ctx, slog.LevelDebug)
+	if !h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want debug enabled by the context level")
+	}
+
+	r := slog.NewRecord(time.Time{}, slog.LevelDebug, "message", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "DBG message a=1 request_id=r1\n"; got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,21 @@
+package zerolog
+
+import (
+	"github.com/rs/zerolog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+const funcKey string = "func"
+
+// defaultKeys returns the keys of zerolog, the global field names of zerolog are read on every record
+// so that changes to them still take effect
+func defaultKeys() logger.Keys {
+	return logger.Keys{
+		Time:    zerolog.TimestampFieldName,
+		Level:   zerolog.LevelFieldName,
+		Message: zerolog.MessageFieldName,
+		File:    zerolog.CallerFieldName,
+		Func:    funcKey,
+	}
+}
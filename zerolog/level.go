@@ -0,0 +1,19 @@
+package zerolog
+
+import (
+	"github.com/rs/zerolog"
+
+	logger "github.com/m40Jc001/slog-handler-adapter"
+)
+
+// defaultLevelMap is used when HandlerOptions.LevelMap is empty,
+// levels between two constants of the root package are mapped to the lower one.
+var defaultLevelMap = logger.LevelMap[zerolog.Level]{
+	{Min: logger.LevelTrace, Level: zerolog.TraceLevel},
+	{Min: logger.LevelDebug, Level: zerolog.DebugLevel},
+	{Min: logger.LevelInfo, Level: zerolog.InfoLevel},
+	{Min: logger.LevelWarn, Level: zerolog.WarnLevel},
+	{Min: logger.LevelError, Level: zerolog.ErrorLevel},
+	{Min: logger.LevelPanic, Level: zerolog.PanicLevel},
+	{Min: logger.LevelFatal, Level: zerolog.FatalLevel},
+}